@@ -3,20 +3,42 @@ package tgbot
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"net/http"
+	"net/url"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 )
 
 type botImpl struct {
-	b      *bot.Bot
-	cancel context.CancelFunc
+	b       *bot.Bot
+	cancel  context.CancelFunc
+	limiter *rateLimiter
+	retry   RetryPolicy
 }
 
-func newBotImpl(token string, onUpdate func(*Update)) (*botImpl, error) {
-	b, err := bot.New(token, bot.WithDefaultHandler(func(ctx context.Context, _ *bot.Bot, raw *models.Update) {
+var allowedUpdates = bot.AllowedUpdates{
+	models.AllowedUpdateMessage,
+	models.AllowedUpdateEditedMessage,
+	models.AllowedUpdateChannelPost,
+	models.AllowedUpdateEditedChannelPost,
+	models.AllowedUpdateCallbackQuery,
+	models.AllowedUpdateMyChatMember,
+	models.AllowedUpdateChatMember,
+	models.AllowedUpdateChatJoinRequest,
+	models.AllowedUpdatePollAnswer,
+	models.AllowedUpdateMessageReaction,
+	models.AllowedUpdateInlineQuery,
+	models.AllowedUpdateChosenInlineResult,
+}
+
+func newBotImpl(token string, limits RateLimitConfig, retry RetryPolicy, onUpdate func(*Update)) (*botImpl, error) {
+	b, err := bot.New(token, bot.WithAllowedUpdates(allowedUpdates), bot.WithDefaultHandler(func(ctx context.Context, _ *bot.Bot, raw *models.Update) {
 		u := updateFromModels(raw)
 		if u != nil {
 			onUpdate(u)
@@ -25,7 +47,7 @@ func newBotImpl(token string, onUpdate func(*Update)) (*botImpl, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &botImpl{b: b}, nil
+	return &botImpl{b: b, limiter: newRateLimiter(limits), retry: retry.withDefaults()}, nil
 }
 
 func updateFromModels(raw *models.Update) *Update {
@@ -33,23 +55,138 @@ func updateFromModels(raw *models.Update) *Update {
 		return nil
 	}
 
-	if raw.CallbackQuery != nil {
-		query := callbackQueryFromModels(raw.CallbackQuery)
-		if query != nil {
+	switch {
+	case raw.CallbackQuery != nil:
+		if query := callbackQueryFromModels(raw.CallbackQuery); query != nil {
 			return &Update{CallbackQuery: query}
 		}
+	case raw.Message != nil:
+		return &Update{Message: messageFromModels(raw.Message)}
+	case raw.EditedMessage != nil:
+		return &Update{EditedMessage: messageFromModels(raw.EditedMessage)}
+	case raw.ChannelPost != nil:
+		return &Update{ChannelPost: messageFromModels(raw.ChannelPost)}
+	case raw.EditedChannelPost != nil:
+		return &Update{EditedChannelPost: messageFromModels(raw.EditedChannelPost)}
+	case raw.MyChatMember != nil:
+		return &Update{MyChatMember: chatMemberUpdatedFromModels(raw.MyChatMember)}
+	case raw.ChatMember != nil:
+		return &Update{ChatMember: chatMemberUpdatedFromModels(raw.ChatMember)}
+	case raw.ChatJoinRequest != nil:
+		r := raw.ChatJoinRequest
+		return &Update{ChatJoinRequest: &ChatJoinRequest{
+			Chat:       chatFromModels(r.Chat),
+			From:       &MessageSender{ID: r.From.ID},
+			UserChatID: r.UserChatID,
+			Date:       r.Date,
+			Bio:        r.Bio,
+		}}
+	case raw.PollAnswer != nil:
+		a := raw.PollAnswer
+		answer := &PollAnswer{
+			PollID:    a.PollID,
+			OptionIDs: a.OptionIDs,
+		}
+		if a.User != nil {
+			answer.User = &MessageSender{ID: a.User.ID}
+		}
+		if a.VoterChat != nil {
+			chat := chatFromModels(*a.VoterChat)
+			answer.VoterChat = &chat
+		}
+		return &Update{PollAnswer: answer}
+	case raw.InlineQuery != nil:
+		q := raw.InlineQuery
+		query := &InlineQuery{
+			ID:       q.ID,
+			Query:    q.Query,
+			Offset:   q.Offset,
+			ChatType: q.ChatType,
+		}
+		if q.From != nil {
+			query.From = &MessageSender{ID: q.From.ID}
+		}
+		return &Update{InlineQuery: query}
+	case raw.ChosenInlineResult != nil:
+		r := raw.ChosenInlineResult
+		return &Update{ChosenInlineResult: &ChosenInlineResult{
+			ResultID:        r.ResultID,
+			From:            &MessageSender{ID: r.From.ID},
+			InlineMessageID: r.InlineMessageID,
+			Query:           r.Query,
+		}}
+	case raw.MessageReaction != nil:
+		r := raw.MessageReaction
+		reaction := &MessageReaction{
+			Chat:        chatFromModels(r.Chat),
+			MessageID:   r.MessageID,
+			Date:        r.Date,
+			OldReaction: reactionsFromModels(r.OldReaction),
+			NewReaction: reactionsFromModels(r.NewReaction),
+		}
+		if r.User != nil {
+			reaction.User = &MessageSender{ID: r.User.ID}
+		}
+		if r.ActorChat != nil {
+			chat := chatFromModels(*r.ActorChat)
+			reaction.ActorChat = &chat
+		}
+		return &Update{MessageReaction: reaction}
 	}
+	return nil
+}
 
-	var m *models.Message
-	if raw.Message != nil {
-		m = raw.Message
-	} else if raw.ChannelPost != nil {
-		m = raw.ChannelPost
+func chatFromModels(c models.Chat) Chat {
+	return Chat{ID: c.ID, Type: string(c.Type)}
+}
+
+func chatMemberUpdatedFromModels(u *models.ChatMemberUpdated) *ChatMemberUpdated {
+	return &ChatMemberUpdated{
+		Chat:           chatFromModels(u.Chat),
+		From:           &MessageSender{ID: u.From.ID},
+		Date:           u.Date,
+		OldChatMember:  chatMemberFromModels(u.OldChatMember),
+		NewChatMember:  chatMemberFromModels(u.NewChatMember),
+		ViaJoinRequest: u.ViaJoinRequest,
 	}
-	if m == nil {
-		return nil
+}
+
+func chatMemberFromModels(cm models.ChatMember) ChatMember {
+	member := ChatMember{
+		UserID: chatMemberUserID(cm),
+		Status: ChatMemberStatus(cm.Type),
 	}
-	return &Update{Message: messageFromModels(m)}
+	switch cm.Type {
+	case models.ChatMemberTypeMember:
+		if cm.Member != nil {
+			member.UntilDate = cm.Member.UntilDate
+		}
+	case models.ChatMemberTypeRestricted:
+		if cm.Restricted != nil {
+			member.IsMember = cm.Restricted.IsMember
+			member.UntilDate = cm.Restricted.UntilDate
+		}
+	case models.ChatMemberTypeBanned:
+		if cm.Banned != nil {
+			member.UntilDate = cm.Banned.UntilDate
+		}
+	}
+	return member
+}
+
+func reactionsFromModels(reactions []models.ReactionType) []Reaction {
+	result := make([]Reaction, 0, len(reactions))
+	for _, r := range reactions {
+		reaction := Reaction{Type: string(r.Type)}
+		if r.ReactionTypeEmoji != nil {
+			reaction.Emoji = r.ReactionTypeEmoji.Emoji
+		}
+		if r.ReactionTypeCustomEmoji != nil {
+			reaction.CustomEmojiID = r.ReactionTypeCustomEmoji.CustomEmojiID
+		}
+		result = append(result, reaction)
+	}
+	return result
 }
 
 func messageFromModels(m *models.Message) *Message {
@@ -57,16 +194,192 @@ func messageFromModels(m *models.Message) *Message {
 		return nil
 	}
 	msg := &Message{
-		MessageID: m.ID,
-		Chat:      Chat{ID: m.Chat.ID, Type: string(m.Chat.Type)},
-		Text:      m.Text,
+		MessageID:       m.ID,
+		Chat:            chatFromModels(m.Chat),
+		Text:            m.Text,
+		Entities:        entitiesFromModels(m.Entities),
+		Caption:         m.Caption,
+		CaptionEntities: entitiesFromModels(m.CaptionEntities),
 	}
 	if m.From != nil {
 		msg.From = &MessageSender{ID: m.From.ID}
 	}
+	if m.ReplyToMessage != nil {
+		msg.ReplyToMessageID = m.ReplyToMessage.ID
+		msg.ReplyToMessage = messageFromModels(m.ReplyToMessage)
+	}
+	if len(m.Photo) > 0 {
+		msg.Photo = make([]PhotoSize, 0, len(m.Photo))
+		for _, p := range m.Photo {
+			msg.Photo = append(msg.Photo, photoSizeFromModels(p))
+		}
+	}
+	if m.Document != nil {
+		msg.Document = &Document{
+			FileID:       m.Document.FileID,
+			FileUniqueID: m.Document.FileUniqueID,
+			FileName:     m.Document.FileName,
+			MimeType:     m.Document.MimeType,
+			FileSize:     m.Document.FileSize,
+		}
+	}
+	if m.Voice != nil {
+		msg.Voice = &Voice{
+			FileID:       m.Voice.FileID,
+			FileUniqueID: m.Voice.FileUniqueID,
+			Duration:     m.Voice.Duration,
+			MimeType:     m.Voice.MimeType,
+			FileSize:     m.Voice.FileSize,
+		}
+	}
+	if m.Video != nil {
+		msg.Video = &Video{
+			FileID:       m.Video.FileID,
+			FileUniqueID: m.Video.FileUniqueID,
+			Width:        m.Video.Width,
+			Height:       m.Video.Height,
+			Duration:     m.Video.Duration,
+			FileName:     m.Video.FileName,
+			MimeType:     m.Video.MimeType,
+			FileSize:     m.Video.FileSize,
+		}
+	}
+	if m.Audio != nil {
+		msg.Audio = &Audio{
+			FileID:       m.Audio.FileID,
+			FileUniqueID: m.Audio.FileUniqueID,
+			Duration:     m.Audio.Duration,
+			Performer:    m.Audio.Performer,
+			Title:        m.Audio.Title,
+			FileName:     m.Audio.FileName,
+			MimeType:     m.Audio.MimeType,
+			FileSize:     m.Audio.FileSize,
+		}
+	}
+	if m.Sticker != nil {
+		msg.Sticker = &Sticker{
+			FileID:       m.Sticker.FileID,
+			FileUniqueID: m.Sticker.FileUniqueID,
+			Emoji:        m.Sticker.Emoji,
+			SetName:      m.Sticker.SetName,
+			IsAnimated:   m.Sticker.IsAnimated,
+			IsVideo:      m.Sticker.IsVideo,
+			FileSize:     m.Sticker.FileSize,
+		}
+	}
+	if m.Contact != nil {
+		msg.Contact = &Contact{
+			PhoneNumber: m.Contact.PhoneNumber,
+			FirstName:   m.Contact.FirstName,
+			LastName:    m.Contact.LastName,
+			UserID:      m.Contact.UserID,
+		}
+	}
+	if m.Location != nil {
+		msg.Location = &Location{
+			Latitude:  m.Location.Latitude,
+			Longitude: m.Location.Longitude,
+		}
+	}
+	if m.Animation != nil {
+		msg.Animation = &Animation{
+			FileID:       m.Animation.FileID,
+			FileUniqueID: m.Animation.FileUniqueID,
+			Width:        m.Animation.Width,
+			Height:       m.Animation.Height,
+			Duration:     m.Animation.Duration,
+			FileName:     m.Animation.FileName,
+			MimeType:     m.Animation.MimeType,
+			FileSize:     m.Animation.FileSize,
+		}
+	}
+	if m.VideoNote != nil {
+		msg.VideoNote = &VideoNote{
+			FileID:       m.VideoNote.FileID,
+			FileUniqueID: m.VideoNote.FileUniqueID,
+			Length:       m.VideoNote.Length,
+			Duration:     m.VideoNote.Duration,
+			FileSize:     m.VideoNote.FileSize,
+		}
+	}
+	if m.Poll != nil {
+		msg.Poll = pollFromModels(m.Poll)
+	}
+	if m.Dice != nil {
+		msg.Dice = &Dice{Emoji: m.Dice.Emoji, Value: m.Dice.Value}
+	}
 	return msg
 }
 
+func pollFromModels(p *models.Poll) *Poll {
+	poll := &Poll{
+		ID:                    p.ID,
+		Question:              p.Question,
+		TotalVoterCount:       p.TotalVoterCount,
+		IsClosed:              p.IsClosed,
+		IsAnonymous:           p.IsAnonymous,
+		Type:                  PollType(p.Type),
+		AllowsMultipleAnswers: p.AllowsMultipleAnswers,
+		CorrectOptionID:       p.CorrectOptionID,
+	}
+	for _, o := range p.Options {
+		poll.Options = append(poll.Options, PollOption{Text: o.Text, VoterCount: o.VoterCount})
+	}
+	return poll
+}
+
+func entitiesFromModels(entities []models.MessageEntity) []MessageEntity {
+	if len(entities) == 0 {
+		return nil
+	}
+	result := make([]MessageEntity, 0, len(entities))
+	for _, e := range entities {
+		entity := MessageEntity{
+			Type:     MessageEntityType(e.Type),
+			Offset:   e.Offset,
+			Length:   e.Length,
+			URL:      e.URL,
+			Language: e.Language,
+		}
+		if e.User != nil {
+			entity.User = &MessageSender{ID: e.User.ID}
+		}
+		result = append(result, entity)
+	}
+	return result
+}
+
+func entitiesToModels(entities []MessageEntity) []models.MessageEntity {
+	if len(entities) == 0 {
+		return nil
+	}
+	result := make([]models.MessageEntity, 0, len(entities))
+	for _, e := range entities {
+		entity := models.MessageEntity{
+			Type:     models.MessageEntityType(e.Type),
+			Offset:   e.Offset,
+			Length:   e.Length,
+			URL:      e.URL,
+			Language: e.Language,
+		}
+		if e.User != nil {
+			entity.User = &models.User{ID: e.User.ID}
+		}
+		result = append(result, entity)
+	}
+	return result
+}
+
+func photoSizeFromModels(p models.PhotoSize) PhotoSize {
+	return PhotoSize{
+		FileID:       p.FileID,
+		FileUniqueID: p.FileUniqueID,
+		Width:        p.Width,
+		Height:       p.Height,
+		FileSize:     p.FileSize,
+	}
+}
+
 func callbackQueryFromModels(q *models.CallbackQuery) *CallbackQuery {
 	if q == nil {
 		return nil
@@ -84,10 +397,7 @@ func callbackQueryFromModels(q *models.CallbackQuery) *CallbackQuery {
 	} else if q.Message.InaccessibleMessage != nil {
 		query.Message = &Message{
 			MessageID: q.Message.InaccessibleMessage.MessageID,
-			Chat: Chat{
-				ID:   q.Message.InaccessibleMessage.Chat.ID,
-				Type: string(q.Message.InaccessibleMessage.Chat.Type),
-			},
+			Chat:      chatFromModels(q.Message.InaccessibleMessage.Chat),
 		}
 	}
 
@@ -108,12 +418,33 @@ func (bi *botImpl) setCancel(cancel context.CancelFunc) {
 	bi.cancel = cancel
 }
 
+func (bi *botImpl) GetMe(ctx context.Context) (*BotIdentity, error) {
+	var me *models.User
+	err := bi.do(ctx, func() (err error) {
+		me, err = bi.b.GetMe(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &BotIdentity{
+		ID:                      me.ID,
+		Username:                me.Username,
+		FirstName:               me.FirstName,
+		CanJoinGroups:           me.CanJoinGroups,
+		CanReadAllGroupMessages: me.CanReadAllGroupMessages,
+		SupportsInlineQueries:   me.SupportInlineQueries,
+	}, nil
+}
+
 func convertParseMode(pm ParseMode) models.ParseMode {
 	switch pm {
 	case ParseModeHTML:
 		return models.ParseModeHTML
 	case ParseModeMarkdown:
 		return models.ParseModeMarkdown
+	case ParseModeMarkdownLegacy:
+		return models.ParseModeMarkdownV1
 	default:
 		return ""
 	}
@@ -127,30 +458,145 @@ func convertReplyMarkup(markup ReplyMarkup) models.ReplyMarkup {
 		if m == nil {
 			return nil
 		}
-		rows := make([][]models.InlineKeyboardButton, 0, len(m.InlineKeyboard))
+		rows := make([][]inlineKeyboardButton, 0, len(m.InlineKeyboard))
 		for _, row := range m.InlineKeyboard {
-			btns := make([]models.InlineKeyboardButton, 0, len(row))
+			btns := make([]inlineKeyboardButton, 0, len(row))
 			for _, btn := range row {
-				btns = append(btns, models.InlineKeyboardButton{
-					Text:         btn.Text,
-					URL:          btn.URL,
-					CallbackData: btn.CallbackData,
-				})
+				btns = append(btns, convertInlineKeyboardButton(btn))
 			}
 			rows = append(rows, btns)
 		}
-		return &models.InlineKeyboardMarkup{InlineKeyboard: rows}
+		return &inlineKeyboardMarkup{InlineKeyboard: rows}
 	case InlineKeyboardMarkup:
 		return convertReplyMarkup(&m)
+	case *ReplyKeyboardMarkup:
+		if m == nil {
+			return nil
+		}
+		rows := make([][]models.KeyboardButton, 0, len(m.Keyboard))
+		for _, row := range m.Keyboard {
+			btns := make([]models.KeyboardButton, 0, len(row))
+			for _, btn := range row {
+				btns = append(btns, models.KeyboardButton{
+					Text:            btn.Text,
+					RequestContact:  btn.RequestContact,
+					RequestLocation: btn.RequestLocation,
+				})
+			}
+			rows = append(rows, btns)
+		}
+		return &models.ReplyKeyboardMarkup{
+			Keyboard:              rows,
+			IsPersistent:          m.IsPersistent,
+			ResizeKeyboard:        m.ResizeKeyboard,
+			OneTimeKeyboard:       m.OneTimeKeyboard,
+			InputFieldPlaceholder: m.InputFieldPlaceholder,
+			Selective:             m.Selective,
+		}
+	case ReplyKeyboardMarkup:
+		return convertReplyMarkup(&m)
+	case *ReplyKeyboardRemove:
+		if m == nil {
+			return nil
+		}
+		return &models.ReplyKeyboardRemove{RemoveKeyboard: true, Selective: m.Selective}
+	case ReplyKeyboardRemove:
+		return convertReplyMarkup(&m)
+	case *ForceReply:
+		if m == nil {
+			return nil
+		}
+		return &models.ForceReply{
+			ForceReply:            true,
+			InputFieldPlaceholder: m.InputFieldPlaceholder,
+			Selective:             m.Selective,
+		}
+	case ForceReply:
+		return convertReplyMarkup(&m)
 	default:
 		return nil
 	}
 }
 
+type inlineKeyboardMarkup struct {
+	InlineKeyboard [][]inlineKeyboardButton `json:"inline_keyboard"`
+}
+
+// inlineKeyboardButton overrides the library's fields that can't be sent
+// empty or left out: switch queries may be "", and copy_text must be omitted
+// when unused. Fields of the outer struct take precedence when encoding.
+type inlineKeyboardButton struct {
+	models.InlineKeyboardButton
+	SwitchInlineQuery            *string                `json:"switch_inline_query,omitempty"`
+	SwitchInlineQueryCurrentChat *string                `json:"switch_inline_query_current_chat,omitempty"`
+	CopyText                     *models.CopyTextButton `json:"copy_text,omitempty"`
+}
+
+func convertInlineKeyboardButton(btn InlineKeyboardButton) inlineKeyboardButton {
+	b := inlineKeyboardButton{
+		InlineKeyboardButton: models.InlineKeyboardButton{
+			Text:         btn.Text,
+			URL:          btn.URL,
+			CallbackData: btn.CallbackData,
+			Pay:          btn.Pay,
+		},
+		SwitchInlineQuery:            btn.SwitchInlineQuery,
+		SwitchInlineQueryCurrentChat: btn.SwitchInlineQueryCurrentChat,
+	}
+	if btn.CopyText != "" {
+		b.CopyText = &models.CopyTextButton{Text: btn.CopyText}
+	}
+	if btn.WebApp != nil {
+		b.WebApp = &models.WebAppInfo{URL: btn.WebApp.URL}
+	}
+	if btn.LoginURL != nil {
+		b.LoginURL = &models.LoginURL{
+			URL:                btn.LoginURL.URL,
+			ForwardText:        btn.LoginURL.ForwardText,
+			BotUsername:        btn.LoginURL.BotUsername,
+			RequestWriteAccess: btn.LoginURL.RequestWriteAccess,
+		}
+	}
+	if btn.SwitchInlineQueryChosenChat != nil {
+		b.SwitchInlineQueryChosenChat = &models.SwitchInlineQueryChosenChat{
+			Query:             btn.SwitchInlineQueryChosenChat.Query,
+			AllowUserChats:    btn.SwitchInlineQueryChosenChat.AllowUserChats,
+			AllowBotChats:     btn.SwitchInlineQueryChosenChat.AllowBotChats,
+			AllowGroupChats:   btn.SwitchInlineQueryChosenChat.AllowGroupChats,
+			AllowChannelChats: btn.SwitchInlineQueryChosenChat.AllowChannelChats,
+		}
+	}
+	if btn.CallbackGame {
+		b.CallbackGame = &models.CallbackGame{}
+	}
+	return b
+}
+
+// statusCodePattern extracts the status code of responses the library
+// reports without a typed error, which are mostly 5xx errors.
+var statusCodePattern = regexp.MustCompile(errTelegramResponse + ` for method \w+, (\d{3}) `)
+
 func mapSendError(err error) error {
 	if err == nil {
 		return nil
 	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	var flood *bot.TooManyRequestsError
+	if errors.As(err, &flood) {
+		return &TooManyRequestsError{
+			RetryAfter:  time.Duration(flood.RetryAfter) * time.Second,
+			Description: flood.Message,
+		}
+	}
+	var migrate *bot.MigrateError
+	if errors.As(err, &migrate) {
+		return &ChatMigratedError{
+			NewChatID:   int64(migrate.MigrateToChatID),
+			Description: migrate.Message,
+		}
+	}
 	if errors.Is(err, bot.ErrorForbidden) {
 		return ErrForbidden
 	}
@@ -158,37 +604,143 @@ func mapSendError(err error) error {
 	if strings.Contains(msg, errChatNotFound) || strings.Contains(msg, errNotMember) {
 		return ErrChatNotFound
 	}
+	if strings.Contains(msg, errMessageNotModified) {
+		return ErrMessageNotModified
+	}
+	if strings.Contains(msg, errMessageTooLong) || strings.Contains(msg, errCaptionTooLong) {
+		return ErrMessageTooLong
+	}
+	switch {
+	case errors.Is(err, bot.ErrorBadRequest), errors.Is(err, bot.ErrorNotFound):
+		return fmt.Errorf("%w: %w", ErrBadRequest, err)
+	case errors.Is(err, bot.ErrorUnauthorized):
+		return fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	case strings.Contains(msg, errDoRequest):
+		return fmt.Errorf("%w: %w", ErrNetwork, err)
+	}
+	if m := statusCodePattern.FindStringSubmatch(msg); m != nil && m[1] >= "500" {
+		return fmt.Errorf("%w: %w", ErrServerError, err)
+	}
+	// Gateway errors come with an HTML page instead of a JSON response.
+	if strings.Contains(msg, errDecodeBody) {
+		return fmt.Errorf("%w: %w", ErrServerError, err)
+	}
 	return err
 }
 
-func (bi *botImpl) SendMessage(ctx context.Context, chatID int64, text string, opts *SendMessageOpts) error {
+// do performs call with the retry policy, mapping its error.
+func (bi *botImpl) do(ctx context.Context, call func() error) error {
+	return bi.retry.do(ctx, true, func() error {
+		return mapSendError(call())
+	})
+}
+
+// send performs call once the rate limiter allows a message to chatID,
+// retrying transient failures.
+func (bi *botImpl) send(ctx context.Context, chatID int64, call func() (*models.Message, error)) (*Message, error) {
+	return bi.sendRetrying(ctx, chatID, true, call)
+}
+
+// sendFile is send for uploads of file, which can only be retried when the
+// content can be read again. call must convert file on every attempt.
+func (bi *botImpl) sendFile(ctx context.Context, chatID int64, file InputFile, call func() (*models.Message, error)) (*Message, error) {
+	return bi.sendRetrying(ctx, chatID, file.rereadable(), call)
+}
+
+func (bi *botImpl) sendRetrying(ctx context.Context, chatID int64, retryable bool, call func() (*models.Message, error)) (*Message, error) {
+	var m *models.Message
+	err := bi.retry.do(ctx, retryable, func() error {
+		if err := bi.limiter.wait(ctx, chatID); err != nil {
+			return err
+		}
+		var err error
+		m, err = call()
+		err = mapSendError(err)
+		bi.limiter.observe(chatID, err, bi.retry.MaxDelay)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return messageFromModels(m), nil
+}
+
+func (bi *botImpl) SendMessage(ctx context.Context, chatID int64, text string, opts *SendMessageOpts) (*Message, error) {
 	params := &bot.SendMessageParams{
 		ChatID: chatID,
 		Text:   text,
 	}
 	if opts != nil {
+		if err := validateReplyMarkup(opts.ReplyMarkup); err != nil {
+			return nil, err
+		}
 		params.ParseMode = convertParseMode(opts.ParseMode)
+		params.Entities = entitiesToModels(opts.Entities)
 		if opts.ReplyToMessageID != 0 {
 			params.ReplyParameters = &models.ReplyParameters{MessageID: opts.ReplyToMessageID}
 		}
 		params.ReplyMarkup = convertReplyMarkup(opts.ReplyMarkup)
 	}
-	_, err := bi.b.SendMessage(ctx, params)
-	return mapSendError(err)
+	return bi.send(ctx, chatID, func() (*models.Message, error) {
+		return bi.b.SendMessage(ctx, params)
+	})
 }
 
-func (bi *botImpl) SendPhoto(ctx context.Context, chatID int64, photo io.Reader, filename string) error {
-	_, err := bi.b.SendPhoto(ctx, &bot.SendPhotoParams{
-		ChatID: chatID,
-		Photo:  &models.InputFileUpload{Filename: filename, Data: photo},
+// mediaParams holds the fields shared by every media send, converted from
+// SendMediaOpts.
+type mediaParams struct {
+	caption         string
+	parseMode       models.ParseMode
+	replyParameters *models.ReplyParameters
+	replyMarkup     models.ReplyMarkup
+}
+
+func convertMediaOpts(opts *SendMediaOpts) (mediaParams, error) {
+	var p mediaParams
+	if opts == nil {
+		return p, nil
+	}
+	if err := validateReplyMarkup(opts.ReplyMarkup); err != nil {
+		return p, err
+	}
+	p.caption = opts.Caption
+	p.parseMode = convertParseMode(opts.ParseMode)
+	if opts.ReplyToMessageID != 0 {
+		p.replyParameters = &models.ReplyParameters{MessageID: opts.ReplyToMessageID}
+	}
+	p.replyMarkup = convertReplyMarkup(opts.ReplyMarkup)
+	return p, nil
+}
+
+func (bi *botImpl) SendPhoto(ctx context.Context, chatID int64, photo InputFile, opts *SendMediaOpts) (*Message, error) {
+	p, err := convertMediaOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+	params := &bot.SendPhotoParams{
+		ChatID:          chatID,
+		Caption:         p.caption,
+		ParseMode:       p.parseMode,
+		ReplyParameters: p.replyParameters,
+		ReplyMarkup:     p.replyMarkup,
+	}
+	return bi.sendFile(ctx, chatID, photo, func() (*models.Message, error) {
+		params.Photo = photo.toModels()
+		return bi.b.SendPhoto(ctx, params)
 	})
-	return mapSendError(err)
 }
 
-func (bi *botImpl) SendVideo(ctx context.Context, chatID int64, video io.Reader, filename string, meta *VideoMeta) error {
+func (bi *botImpl) SendVideo(ctx context.Context, chatID int64, video InputFile, meta *VideoMeta, opts *SendMediaOpts) (*Message, error) {
+	p, err := convertMediaOpts(opts)
+	if err != nil {
+		return nil, err
+	}
 	params := &bot.SendVideoParams{
-		ChatID: chatID,
-		Video:  &models.InputFileUpload{Filename: filename, Data: video},
+		ChatID:          chatID,
+		Caption:         p.caption,
+		ParseMode:       p.parseMode,
+		ReplyParameters: p.replyParameters,
+		ReplyMarkup:     p.replyMarkup,
 	}
 	if meta != nil {
 		if meta.Duration > 0 {
@@ -201,29 +753,584 @@ func (bi *botImpl) SendVideo(ctx context.Context, chatID int64, video io.Reader,
 			params.Height = meta.Height
 		}
 	}
-	_, err := bi.b.SendVideo(ctx, params)
-	return mapSendError(err)
+	return bi.sendFile(ctx, chatID, video, func() (*models.Message, error) {
+		params.Video = video.toModels()
+		return bi.b.SendVideo(ctx, params)
+	})
+}
+
+func (bi *botImpl) SendAudio(ctx context.Context, chatID int64, audio InputFile, opts *SendMediaOpts) (*Message, error) {
+	p, err := convertMediaOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+	params := &bot.SendAudioParams{
+		ChatID:          chatID,
+		Caption:         p.caption,
+		ParseMode:       p.parseMode,
+		ReplyParameters: p.replyParameters,
+		ReplyMarkup:     p.replyMarkup,
+	}
+	return bi.sendFile(ctx, chatID, audio, func() (*models.Message, error) {
+		params.Audio = audio.toModels()
+		return bi.b.SendAudio(ctx, params)
+	})
+}
+
+func (bi *botImpl) SendDocument(ctx context.Context, chatID int64, doc InputFile, opts *SendMediaOpts) (*Message, error) {
+	p, err := convertMediaOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+	params := &bot.SendDocumentParams{
+		ChatID:          chatID,
+		Caption:         p.caption,
+		ParseMode:       p.parseMode,
+		ReplyParameters: p.replyParameters,
+		ReplyMarkup:     p.replyMarkup,
+	}
+	return bi.sendFile(ctx, chatID, doc, func() (*models.Message, error) {
+		params.Document = doc.toModels()
+		return bi.b.SendDocument(ctx, params)
+	})
+}
+
+func (bi *botImpl) SendVoice(ctx context.Context, chatID int64, voice InputFile, opts *SendMediaOpts) (*Message, error) {
+	p, err := convertMediaOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+	params := &bot.SendVoiceParams{
+		ChatID:          chatID,
+		Caption:         p.caption,
+		ParseMode:       p.parseMode,
+		ReplyParameters: p.replyParameters,
+		ReplyMarkup:     p.replyMarkup,
+	}
+	return bi.sendFile(ctx, chatID, voice, func() (*models.Message, error) {
+		params.Voice = voice.toModels()
+		return bi.b.SendVoice(ctx, params)
+	})
+}
+
+func (bi *botImpl) SendVideoNote(ctx context.Context, chatID int64, note InputFile, opts *SendMediaOpts) (*Message, error) {
+	p, err := convertMediaOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+	params := &bot.SendVideoNoteParams{
+		ChatID:          chatID,
+		ReplyParameters: p.replyParameters,
+		ReplyMarkup:     p.replyMarkup,
+	}
+	return bi.sendFile(ctx, chatID, note, func() (*models.Message, error) {
+		params.VideoNote = note.toModels()
+		return bi.b.SendVideoNote(ctx, params)
+	})
+}
+
+func (bi *botImpl) SendAnimation(ctx context.Context, chatID int64, animation InputFile, opts *SendMediaOpts) (*Message, error) {
+	p, err := convertMediaOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+	params := &bot.SendAnimationParams{
+		ChatID:          chatID,
+		Caption:         p.caption,
+		ParseMode:       p.parseMode,
+		ReplyParameters: p.replyParameters,
+		ReplyMarkup:     p.replyMarkup,
+	}
+	return bi.sendFile(ctx, chatID, animation, func() (*models.Message, error) {
+		params.Animation = animation.toModels()
+		return bi.b.SendAnimation(ctx, params)
+	})
+}
+
+func (bi *botImpl) SendSticker(ctx context.Context, chatID int64, sticker InputFile, opts *SendMediaOpts) (*Message, error) {
+	p, err := convertMediaOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+	params := &bot.SendStickerParams{
+		ChatID:          chatID,
+		ReplyParameters: p.replyParameters,
+		ReplyMarkup:     p.replyMarkup,
+	}
+	return bi.sendFile(ctx, chatID, sticker, func() (*models.Message, error) {
+		params.Sticker = sticker.toModels()
+		return bi.b.SendSticker(ctx, params)
+	})
+}
+
+// convertMessageOpts converts the reply-to and markup of opts for sends
+// without text, where ParseMode has no effect.
+func convertMessageOpts(opts *SendMessageOpts) (mediaParams, error) {
+	if opts == nil {
+		return mediaParams{}, nil
+	}
+	return convertMediaOpts(&SendMediaOpts{
+		ReplyToMessageID: opts.ReplyToMessageID,
+		ReplyMarkup:      opts.ReplyMarkup,
+	})
+}
+
+func (bi *botImpl) SendLocation(ctx context.Context, chatID int64, location Location, opts *SendMessageOpts) (*Message, error) {
+	p, err := convertMessageOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+	params := &bot.SendLocationParams{
+		ChatID:          chatID,
+		Latitude:        location.Latitude,
+		Longitude:       location.Longitude,
+		ReplyParameters: p.replyParameters,
+		ReplyMarkup:     p.replyMarkup,
+	}
+	return bi.send(ctx, chatID, func() (*models.Message, error) {
+		return bi.b.SendLocation(ctx, params)
+	})
+}
+
+func (bi *botImpl) SendVenue(ctx context.Context, chatID int64, venue Venue, opts *SendMessageOpts) (*Message, error) {
+	p, err := convertMessageOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+	params := &bot.SendVenueParams{
+		ChatID:          chatID,
+		Latitude:        venue.Location.Latitude,
+		Longitude:       venue.Location.Longitude,
+		Title:           venue.Title,
+		Address:         venue.Address,
+		ReplyParameters: p.replyParameters,
+		ReplyMarkup:     p.replyMarkup,
+	}
+	return bi.send(ctx, chatID, func() (*models.Message, error) {
+		return bi.b.SendVenue(ctx, params)
+	})
+}
+
+func (bi *botImpl) SendContact(ctx context.Context, chatID int64, contact Contact, opts *SendMessageOpts) (*Message, error) {
+	p, err := convertMessageOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+	params := &bot.SendContactParams{
+		ChatID:          chatID,
+		PhoneNumber:     contact.PhoneNumber,
+		FirstName:       contact.FirstName,
+		LastName:        contact.LastName,
+		ReplyParameters: p.replyParameters,
+		ReplyMarkup:     p.replyMarkup,
+	}
+	return bi.send(ctx, chatID, func() (*models.Message, error) {
+		return bi.b.SendContact(ctx, params)
+	})
+}
+
+func (bi *botImpl) SendPoll(ctx context.Context, chatID int64, poll PollConfig, opts *SendMessageOpts) (*Message, error) {
+	p, err := convertMessageOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+	options := make([]models.InputPollOption, 0, len(poll.Options))
+	for _, o := range poll.Options {
+		options = append(options, models.InputPollOption{Text: o})
+	}
+	isAnonymous := !poll.IsPublic
+	params := &bot.SendPollParams{
+		ChatID:                chatID,
+		Question:              poll.Question,
+		Options:               options,
+		IsAnonymous:           &isAnonymous,
+		Type:                  string(poll.Type),
+		AllowsMultipleAnswers: poll.AllowsMultipleAnswers,
+		CorrectOptionID:       poll.CorrectOptionID,
+		Explanation:           poll.Explanation,
+		ExplanationParseMode:  string(convertParseMode(poll.ExplanationParseMode)),
+		OpenPeriod:            poll.OpenPeriod,
+		ReplyParameters:       p.replyParameters,
+		ReplyMarkup:           p.replyMarkup,
+	}
+	return bi.send(ctx, chatID, func() (*models.Message, error) {
+		return bi.b.SendPoll(ctx, params)
+	})
+}
+
+func (bi *botImpl) SendDice(ctx context.Context, chatID int64, emoji string, opts *SendMessageOpts) (*Message, error) {
+	p, err := convertMessageOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+	params := &bot.SendDiceParams{
+		ChatID:          chatID,
+		Emoji:           emoji,
+		ReplyParameters: p.replyParameters,
+		ReplyMarkup:     p.replyMarkup,
+	}
+	return bi.send(ctx, chatID, func() (*models.Message, error) {
+		return bi.b.SendDice(ctx, params)
+	})
+}
+
+// attachName keeps the characters that are safe in both a multipart field
+// name and an attach:// reference.
+func attachName(filename string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, filename)
+}
+
+// convertInputMedia refers to uploads as attach://<filename>. The library
+// uses that name both as the multipart field and as the filename Telegram
+// shows, so names are sanitized and prefixed with the index when repeated.
+func convertInputMedia(media []InputMedia) []models.InputMedia {
+	items := make([]models.InputMedia, 0, len(media))
+	names := make(map[string]bool, len(media))
+	for idx, item := range media {
+		ref := item.File.fileID + item.File.url
+		var attachment io.Reader
+		if item.File.IsUpload() {
+			name := attachName(item.File.uploadFilename())
+			if names[name] {
+				name = fmt.Sprintf("%d_%s", idx, name)
+			}
+			names[name] = true
+			ref = "attach://" + name
+			attachment = item.File.content()
+		}
+		parseMode := convertParseMode(item.ParseMode)
+		switch item.Type {
+		case InputMediaVideo:
+			items = append(items, &models.InputMediaVideo{Media: ref, Caption: item.Caption, ParseMode: parseMode, MediaAttachment: attachment})
+		case InputMediaDocument:
+			items = append(items, &models.InputMediaDocument{Media: ref, Caption: item.Caption, ParseMode: parseMode, MediaAttachment: attachment})
+		case InputMediaAudio:
+			items = append(items, &models.InputMediaAudio{Media: ref, Caption: item.Caption, ParseMode: parseMode, MediaAttachment: attachment})
+		default:
+			items = append(items, &models.InputMediaPhoto{Media: ref, Caption: item.Caption, ParseMode: parseMode, MediaAttachment: attachment})
+		}
+	}
+	return items
+}
+
+func (bi *botImpl) SendMediaGroup(ctx context.Context, chatID int64, media []InputMedia, replyToMessageID int) ([]*Message, error) {
+	params := &bot.SendMediaGroupParams{ChatID: chatID}
+	if replyToMessageID != 0 {
+		params.ReplyParameters = &models.ReplyParameters{MessageID: replyToMessageID}
+	}
+	retryable := true
+	for _, item := range media {
+		retryable = retryable && item.File.rereadable()
+	}
+	var result []*models.Message
+	err := bi.retry.do(ctx, retryable, func() error {
+		// An album is sent in one request and counts as one message.
+		if err := bi.limiter.wait(ctx, chatID); err != nil {
+			return err
+		}
+		params.Media = convertInputMedia(media)
+		var err error
+		result, err = bi.b.SendMediaGroup(ctx, params)
+		err = mapSendError(err)
+		bi.limiter.observe(chatID, err, bi.retry.MaxDelay)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]*Message, 0, len(result))
+	for _, m := range result {
+		msgs = append(msgs, messageFromModels(m))
+	}
+	return msgs, nil
+}
+
+func (bi *botImpl) EditMessageText(ctx context.Context, chatID int64, messageID int, text string, opts *EditMessageOpts) (*Message, error) {
+	params := &bot.EditMessageTextParams{
+		ChatID:    chatID,
+		MessageID: messageID,
+		Text:      text,
+	}
+	if opts != nil {
+		if err := validateReplyMarkup(opts.ReplyMarkup); err != nil {
+			return nil, err
+		}
+		params.ParseMode = convertParseMode(opts.ParseMode)
+		params.ReplyMarkup = convertReplyMarkup(opts.ReplyMarkup)
+	}
+	return bi.send(ctx, chatID, func() (*models.Message, error) {
+		return bi.b.EditMessageText(ctx, params)
+	})
+}
+
+func (bi *botImpl) EditMessageReplyMarkup(ctx context.Context, chatID int64, messageID int, markup ReplyMarkup) (*Message, error) {
+	if err := validateReplyMarkup(markup); err != nil {
+		return nil, err
+	}
+	params := &bot.EditMessageReplyMarkupParams{
+		ChatID:      chatID,
+		MessageID:   messageID,
+		ReplyMarkup: convertReplyMarkup(markup),
+	}
+	return bi.send(ctx, chatID, func() (*models.Message, error) {
+		return bi.b.EditMessageReplyMarkup(ctx, params)
+	})
 }
 
-func (bi *botImpl) SendAudio(ctx context.Context, chatID int64, audio io.Reader, filename string) error {
-	_, err := bi.b.SendAudio(ctx, &bot.SendAudioParams{
+// inlineEditError ignores the error the library reports for the true result
+// of inline message edits, which it can't decode as a message.
+func inlineEditError(err error) error {
+	if err != nil && strings.Contains(err.Error(), errDecodeResult) {
+		return nil
+	}
+	return err
+}
+
+func (bi *botImpl) EditInlineMessageText(ctx context.Context, inlineMessageID string, text string, opts *EditMessageOpts) error {
+	params := &bot.EditMessageTextParams{
+		InlineMessageID: inlineMessageID,
+		Text:            text,
+	}
+	if opts != nil {
+		if err := validateReplyMarkup(opts.ReplyMarkup); err != nil {
+			return err
+		}
+		params.ParseMode = convertParseMode(opts.ParseMode)
+		params.ReplyMarkup = convertReplyMarkup(opts.ReplyMarkup)
+	}
+	return bi.do(ctx, func() error {
+		_, err := bi.b.EditMessageText(ctx, params)
+		return inlineEditError(err)
+	})
+}
+
+func (bi *botImpl) EditInlineMessageReplyMarkup(ctx context.Context, inlineMessageID string, markup ReplyMarkup) error {
+	if err := validateReplyMarkup(markup); err != nil {
+		return err
+	}
+	params := &bot.EditMessageReplyMarkupParams{
+		InlineMessageID: inlineMessageID,
+		ReplyMarkup:     convertReplyMarkup(markup),
+	}
+	return bi.do(ctx, func() error {
+		_, err := bi.b.EditMessageReplyMarkup(ctx, params)
+		return inlineEditError(err)
+	})
+}
+
+func (bi *botImpl) DeleteMessage(ctx context.Context, chatID int64, messageID int) error {
+	return bi.do(ctx, func() error {
+		_, err := bi.b.DeleteMessage(ctx, &bot.DeleteMessageParams{ChatID: chatID, MessageID: messageID})
+		return err
+	})
+}
+
+func (bi *botImpl) SendChatAction(ctx context.Context, chatID int64, action ChatAction) error {
+	// Chat actions are repeated anyway, so a failed one isn't retried.
+	_, err := bi.b.SendChatAction(ctx, &bot.SendChatActionParams{
 		ChatID: chatID,
-		Audio:  &models.InputFileUpload{Filename: filename, Data: audio},
+		Action: models.ChatAction(action),
 	})
 	return mapSendError(err)
 }
 
-func (bi *botImpl) SendDocument(ctx context.Context, chatID int64, doc io.Reader, filename string) error {
-	_, err := bi.b.SendDocument(ctx, &bot.SendDocumentParams{
-		ChatID:   chatID,
-		Document: &models.InputFileUpload{Filename: filename, Data: doc},
+func (bi *botImpl) answerCallbackQuery(ctx context.Context, callbackQueryID string, answer *CallbackAnswer) error {
+	params := &bot.AnswerCallbackQueryParams{CallbackQueryID: callbackQueryID}
+	if answer != nil {
+		params.Text = answer.Text
+		params.ShowAlert = answer.ShowAlert
+		params.URL = answer.URL
+		params.CacheTime = answer.CacheTime
+	}
+	return bi.do(ctx, func() error {
+		_, err := bi.b.AnswerCallbackQuery(ctx, params)
+		return err
 	})
-	return mapSendError(err)
 }
 
-func (bi *botImpl) answerCallbackQuery(ctx context.Context, callbackQueryID string) error {
-	_, err := bi.b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: callbackQueryID})
-	return mapSendError(err)
+func (bi *botImpl) getFile(ctx context.Context, fileID string) (*models.File, error) {
+	var f *models.File
+	err := bi.do(ctx, func() (err error) {
+		f, err = bi.b.GetFile(ctx, &bot.GetFileParams{FileID: fileID})
+		return err
+	})
+	return f, err
+}
+
+func (bi *botImpl) GetFile(ctx context.Context, fileID string) (*File, error) {
+	f, err := bi.getFile(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	return &File{
+		FileID:       f.FileID,
+		FileUniqueID: f.FileUniqueID,
+		FileSize:     f.FileSize,
+		FilePath:     f.FilePath,
+	}, nil
+}
+
+// downloadClient fetches files from Telegram. Files served by the Bot API
+// are at most 20MB, so a download taking longer has stalled.
+var downloadClient = &http.Client{Timeout: 5 * time.Minute}
+
+func (bi *botImpl) DownloadFile(ctx context.Context, fileID string, w io.Writer) error {
+	f, err := bi.getFile(ctx, fileID)
+	if err != nil {
+		return err
+	}
+
+	// The download link contains the bot token, so errors must not include
+	// it.
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, bi.b.FileDownloadLink(f), nil)
+	if err != nil {
+		return fmt.Errorf("download file %s: invalid download link", fileID)
+	}
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("%w: download file %s: %w", ErrNetwork, fileID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download file %s: unexpected status %s", fileID, resp.Status)
+	}
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func (bi *botImpl) ApproveChatJoinRequest(ctx context.Context, chatID int64, userID int64) error {
+	return bi.do(ctx, func() error {
+		_, err := bi.b.ApproveChatJoinRequest(ctx, &bot.ApproveChatJoinRequestParams{ChatID: chatID, UserID: userID})
+		return err
+	})
+}
+
+func (bi *botImpl) DeclineChatJoinRequest(ctx context.Context, chatID int64, userID int64) error {
+	return bi.do(ctx, func() error {
+		_, err := bi.b.DeclineChatJoinRequest(ctx, &bot.DeclineChatJoinRequestParams{ChatID: chatID, UserID: userID})
+		return err
+	})
+}
+
+func convertInlineQueryResult(r *InlineQueryResult) models.InlineQueryResult {
+	var markup models.ReplyMarkup
+	if r.ReplyMarkup != nil {
+		markup = convertReplyMarkup(r.ReplyMarkup)
+	}
+	var content models.InputMessageContent
+	if r.MessageText != "" {
+		content = &models.InputTextMessageContent{
+			MessageText: r.MessageText,
+			ParseMode:   convertParseMode(r.ParseMode),
+		}
+	}
+
+	switch r.Type {
+	case InlineResultPhoto:
+		if r.PhotoFileID != "" {
+			return &models.InlineQueryResultCachedPhoto{
+				ID:                  r.ID,
+				PhotoFileID:         r.PhotoFileID,
+				Title:               r.Title,
+				Description:         r.Description,
+				Caption:             r.Caption,
+				ParseMode:           convertParseMode(r.ParseMode),
+				ReplyMarkup:         markup,
+				InputMessageContent: content,
+			}
+		}
+		return &models.InlineQueryResultPhoto{
+			ID:                  r.ID,
+			PhotoURL:            r.PhotoURL,
+			ThumbnailURL:        r.ThumbnailURL,
+			PhotoWidth:          r.PhotoWidth,
+			PhotoHeight:         r.PhotoHeight,
+			Title:               r.Title,
+			Description:         r.Description,
+			Caption:             r.Caption,
+			ParseMode:           convertParseMode(r.ParseMode),
+			ReplyMarkup:         markup,
+			InputMessageContent: content,
+		}
+	case InlineResultDocument:
+		if r.DocumentFileID != "" {
+			return &models.InlineQueryResultCachedDocument{
+				ID:                  r.ID,
+				Title:               r.Title,
+				DocumentFileID:      r.DocumentFileID,
+				Description:         r.Description,
+				Caption:             r.Caption,
+				ParseMode:           convertParseMode(r.ParseMode),
+				ReplyMarkup:         markup,
+				InputMessageContent: content,
+			}
+		}
+		return &models.InlineQueryResultDocument{
+			ID:                  r.ID,
+			Title:               r.Title,
+			Caption:             r.Caption,
+			ParseMode:           convertParseMode(r.ParseMode),
+			DocumentURL:         r.DocumentURL,
+			MimeType:            r.MimeType,
+			Description:         r.Description,
+			ReplyMarkup:         markup,
+			InputMessageContent: content,
+			ThumbnailURL:        r.ThumbnailURL,
+		}
+	default:
+		return &models.InlineQueryResultArticle{
+			ID:                  r.ID,
+			Title:               r.Title,
+			InputMessageContent: content,
+			ReplyMarkup:         markup,
+			URL:                 r.URL,
+			Description:         r.Description,
+			ThumbnailURL:        r.ThumbnailURL,
+		}
+	}
+}
+
+func (bi *botImpl) answerInlineQuery(ctx context.Context, inlineQueryID string, answer *InlineQueryAnswer) error {
+	results := make([]models.InlineQueryResult, 0, len(answer.Results))
+	for _, r := range answer.Results {
+		if r == nil {
+			continue
+		}
+		if err := r.Validate(); err != nil {
+			return err
+		}
+		results = append(results, convertInlineQueryResult(r))
+	}
+	params := &bot.AnswerInlineQueryParams{
+		InlineQueryID: inlineQueryID,
+		Results:       results,
+		CacheTime:     answer.CacheTime,
+		IsPersonal:    answer.IsPersonal,
+		NextOffset:    answer.NextOffset,
+	}
+	if answer.Button != nil {
+		params.Button = &models.InlineQueryResultsButton{
+			Text:           answer.Button.Text,
+			StartParameter: answer.Button.StartParameter,
+		}
+	}
+	return bi.do(ctx, func() error {
+		_, err := bi.b.AnswerInlineQuery(ctx, params)
+		return err
+	})
 }
 
 func chatMemberUserID(cm models.ChatMember) int64 {
@@ -255,7 +1362,11 @@ func chatMemberUserID(cm models.ChatMember) int64 {
 }
 
 func (bi *botImpl) GetChatAdministratorIDs(ctx context.Context, chatID int64) ([]int64, error) {
-	admins, err := bi.b.GetChatAdministrators(ctx, &bot.GetChatAdministratorsParams{ChatID: chatID})
+	var admins []models.ChatMember
+	err := bi.do(ctx, func() (err error) {
+		admins, err = bi.b.GetChatAdministrators(ctx, &bot.GetChatAdministratorsParams{ChatID: chatID})
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,630 @@
+package tgbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Sends run concurrently so that request latency doesn't cap the rate;
+	// the rate limiter keeps them within Telegram's limits.
+	broadcastWorkers        = 8
+	broadcastReportInterval = 5 * time.Second
+
+	// Targets are reserved in storage this many at a time before they are
+	// sent to, which bounds how many are skipped if the process dies.
+	broadcastBatchSize = 50
+)
+
+var (
+	ErrBroadcastRunning = errors.New("a broadcast is already running")
+	ErrUnknownSegment   = errors.New("unknown broadcast segment")
+	ErrEmptyBroadcast   = errors.New("broadcast has no text or file")
+)
+
+type BroadcastState string
+
+const (
+	BroadcastRunning   BroadcastState = "running"
+	BroadcastPaused    BroadcastState = "paused"
+	BroadcastCancelled BroadcastState = "cancelled"
+	BroadcastDone      BroadcastState = "done"
+	BroadcastFailed    BroadcastState = "failed"
+)
+
+// BroadcastContent is the message sent to every recipient: Text alone, or
+// a photo, video, document or audio File with Text as its caption. A file
+// from a reader is read into memory up front, and an upload is sent by its
+// file ID after the first delivery.
+type BroadcastContent struct {
+	Text      string
+	ParseMode ParseMode
+	Media     InputMediaType
+	File      InputFile
+}
+
+type BroadcastConfig struct {
+	// Segment names a filter registered with RegisterBroadcastSegment. The
+	// broadcast goes to all users when it is empty.
+	Segment string
+
+	// ReportChatID, when set, receives a status message that is kept up to
+	// date with the progress of the broadcast, also after it is resumed.
+	ReportChatID int64
+}
+
+// BroadcastProgress counts deliveries; Blocked recipients are also counted
+// in Failed. Skipped recipients were never sent to, because the job was
+// cancelled or the process died while sending to them. Error says why a
+// failed job stopped.
+type BroadcastProgress struct {
+	State   BroadcastState
+	Total   int
+	Sent    int
+	Failed  int
+	Blocked int
+	Skipped int
+	Error   string
+}
+
+func (p BroadcastProgress) String() string {
+	s := fmt.Sprintf("Broadcast %s: %d/%d sent, %d failed (%d blocked), %d skipped.", p.State, p.Sent, p.Total, p.Failed, p.Blocked, p.Skipped)
+	if p.Error != "" {
+		s += "\n" + p.Error
+	}
+	return s
+}
+
+// BroadcastJob delivers a BroadcastContent in the background. Recipients
+// that have blocked the bot are marked blocked like on any other send.
+//
+// Jobs are persisted and resumed when the client starts again. Each batch
+// of recipients is reserved in storage before it is sent to, so nobody gets
+// the message twice; if the process dies mid-batch, the rest of that batch
+// is skipped instead.
+type BroadcastJob[BOTDATA any, USERDATA any] struct {
+	id      string
+	content BroadcastContent
+	config  BroadcastConfig
+	client  *Client[BOTDATA, USERDATA]
+	targets []int64
+	created time.Time
+
+	mu              sync.Mutex
+	resumed         *sync.Cond
+	progress        BroadcastProgress
+	cursor          *int64
+	reserved        int
+	reportMessageID int
+	done            chan struct{}
+}
+
+// Broadcast sends content to every session that isn't blocked and belongs
+// to the configured segment. Content without text, or with Media but no
+// file, fails with ErrEmptyBroadcast.
+func (c *Client[BOTDATA, USERDATA]) Broadcast(content BroadcastContent, config BroadcastConfig) (*BroadcastJob[BOTDATA, USERDATA], error) {
+	filter, err := c.getBroadcastSegment(config.Segment)
+	if err != nil {
+		return nil, err
+	}
+	file := content.File
+	switch {
+	case content.Media == "" && content.Text == "":
+		return nil, ErrEmptyBroadcast
+	case content.Media == "":
+	case file.reader != nil:
+		// The upload is retried on the next recipient if the first one
+		// fails, so it must be readable more than once.
+		data, err := io.ReadAll(file.reader)
+		if err != nil {
+			return nil, err
+		}
+		content.File = FileFromBytes(data, file.filename)
+	case file.IsUpload() && file.data == nil:
+		return nil, ErrEmptyBroadcast
+	}
+
+	job := c.newBroadcastJob(strconv.FormatInt(time.Now().UnixNano(), 36), content, config)
+	job.targets = c.broadcastTargets(filter, nil)
+	job.progress = BroadcastProgress{State: BroadcastRunning, Total: len(job.targets)}
+	job.sendReport()
+	if err := job.save(); err != nil {
+		job.setState(BroadcastRunning, BroadcastCancelled)
+		job.report()
+		return nil, err
+	}
+
+	c.addBroadcastJob(job)
+	go job.run()
+	return job, nil
+}
+
+func (c *Client[BOTDATA, USERDATA]) newBroadcastJob(id string, content BroadcastContent, config BroadcastConfig) *BroadcastJob[BOTDATA, USERDATA] {
+	job := &BroadcastJob[BOTDATA, USERDATA]{
+		id:      id,
+		content: content,
+		config:  config,
+		client:  c,
+		created: time.Now(),
+		done:    make(chan struct{}),
+	}
+	job.resumed = sync.NewCond(&job.mu)
+	return job
+}
+
+// resumeBroadcasts restarts the jobs persisted by a previous run. Jobs
+// whose segment is no longer registered are left in storage.
+func (c *Client[BOTDATA, USERDATA]) resumeBroadcasts() error {
+	stored, err := c.Firebase.GetBroadcasts()
+	if err != nil {
+		return err
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].CreatedAt.Before(stored[j].CreatedAt) })
+
+	for _, record := range stored {
+		filter, err := c.getBroadcastSegment(record.Segment)
+		if err != nil {
+			continue
+		}
+		job := c.newBroadcastJob(record.ID, BroadcastContent{
+			Text:      record.Text,
+			ParseMode: record.ParseMode,
+			Media:     record.Media,
+			File:      record.file(),
+		}, BroadcastConfig{
+			Segment:      record.Segment,
+			ReportChatID: record.ReportChatID,
+		})
+		job.created = record.CreatedAt
+		job.cursor = record.Cursor
+		job.reportMessageID = record.ReportMessageID
+		job.reserved = record.Reserved
+		job.targets = c.broadcastTargets(filter, record.Cursor)
+		// Reserved recipients that weren't accounted for were being sent to
+		// when the process died.
+		job.progress = BroadcastProgress{
+			State:   record.State,
+			Total:   record.Reserved + len(job.targets),
+			Sent:    record.Sent,
+			Failed:  record.Failed,
+			Blocked: record.Blocked,
+			Skipped: record.Reserved - record.Sent - record.Failed,
+		}
+		c.addBroadcastJob(job)
+		go job.run()
+	}
+	return nil
+}
+
+// broadcastTargets returns the IDs of matching sessions after cursor in
+// ascending order.
+func (c *Client[BOTDATA, USERDATA]) broadcastTargets(filter func(*Session[BOTDATA, USERDATA]) bool, cursor *int64) []int64 {
+	c.mu.RLock()
+	sessions := make([]*Session[BOTDATA, USERDATA], 0, len(c.Sessions))
+	for _, session := range c.Sessions {
+		sessions = append(sessions, session)
+	}
+	c.mu.RUnlock()
+
+	targets := make([]int64, 0, len(sessions))
+	for _, session := range sessions {
+		if cursor != nil && session.ID <= *cursor {
+			continue
+		}
+		session.blockedMu.Lock()
+		blocked := session.User.Blocked
+		session.blockedMu.Unlock()
+		if blocked || (filter != nil && !filter(session)) {
+			continue
+		}
+		targets = append(targets, session.ID)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i] < targets[j] })
+	return targets
+}
+
+func (c *Client[BOTDATA, USERDATA]) registerBroadcastSegment(name string, filter func(*Session[BOTDATA, USERDATA]) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.broadcastSegments[name] = filter
+}
+
+func (c *Client[BOTDATA, USERDATA]) getBroadcastSegment(name string) (func(*Session[BOTDATA, USERDATA]) bool, error) {
+	if name == "" {
+		return nil, nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	filter, ok := c.broadcastSegments[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSegment, name)
+	}
+	return filter, nil
+}
+
+func (c *Client[BOTDATA, USERDATA]) addBroadcastJob(job *BroadcastJob[BOTDATA, USERDATA]) {
+	c.broadcastMu.Lock()
+	defer c.broadcastMu.Unlock()
+	c.broadcastJobs[job.id] = job
+}
+
+func (c *Client[BOTDATA, USERDATA]) removeBroadcastJob(job *BroadcastJob[BOTDATA, USERDATA]) {
+	c.broadcastMu.Lock()
+	defer c.broadcastMu.Unlock()
+	delete(c.broadcastJobs, job.id)
+}
+
+// latestBroadcastJob returns the most recently created unfinished job.
+func (c *Client[BOTDATA, USERDATA]) latestBroadcastJob() *BroadcastJob[BOTDATA, USERDATA] {
+	c.broadcastMu.Lock()
+	defer c.broadcastMu.Unlock()
+	var latest *BroadcastJob[BOTDATA, USERDATA]
+	for _, job := range c.broadcastJobs {
+		if latest == nil || job.created.After(latest.created) {
+			latest = job
+		}
+	}
+	return latest
+}
+
+func (job *BroadcastJob[BOTDATA, USERDATA]) ID() string {
+	return job.id
+}
+
+func (job *BroadcastJob[BOTDATA, USERDATA]) Progress() BroadcastProgress {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	return job.progress
+}
+
+// Done is closed once the job has finished or been cancelled.
+func (job *BroadcastJob[BOTDATA, USERDATA]) Done() <-chan struct{} {
+	return job.done
+}
+
+func (job *BroadcastJob[BOTDATA, USERDATA]) Pause() {
+	if job.setState(BroadcastRunning, BroadcastPaused) {
+		job.save()
+	}
+}
+
+func (job *BroadcastJob[BOTDATA, USERDATA]) Resume() {
+	if job.setState(BroadcastPaused, BroadcastRunning) {
+		job.save()
+	}
+}
+
+// Cancel stops the job; sends already in flight still complete.
+func (job *BroadcastJob[BOTDATA, USERDATA]) Cancel() {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	if job.progress.State == BroadcastRunning || job.progress.State == BroadcastPaused {
+		job.progress.State = BroadcastCancelled
+		job.resumed.Broadcast()
+	}
+}
+
+func (job *BroadcastJob[BOTDATA, USERDATA]) setState(from BroadcastState, to BroadcastState) bool {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	if job.progress.State != from {
+		return false
+	}
+	job.progress.State = to
+	job.resumed.Broadcast()
+	return true
+}
+
+// proceed blocks while the job is paused and reports whether it should
+// continue.
+func (job *BroadcastJob[BOTDATA, USERDATA]) proceed() bool {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	for job.progress.State == BroadcastPaused {
+		job.resumed.Wait()
+	}
+	return job.progress.State == BroadcastRunning
+}
+
+func (job *BroadcastJob[BOTDATA, USERDATA]) record(err error) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	switch {
+	case err == nil:
+		job.progress.Sent++
+	case errors.Is(err, ErrForbidden) || errors.Is(err, ErrChatNotFound):
+		job.progress.Blocked++
+		fallthrough
+	default:
+		job.progress.Failed++
+	}
+}
+
+// fail stops the job for good, counting the n remaining targets as skipped.
+func (job *BroadcastJob[BOTDATA, USERDATA]) fail(err error, n int) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	job.progress.State = BroadcastFailed
+	job.progress.Error = err.Error()
+	job.progress.Skipped += n
+	job.resumed.Broadcast()
+}
+
+// isUploadRejected reports whether err rejects the upload itself rather than
+// its recipient, such as a file that is too large or of the wrong type.
+func isUploadRejected(err error) bool {
+	var migrated *ChatMigratedError
+	if errors.As(err, &migrated) {
+		return false
+	}
+	return errors.Is(err, ErrBadRequest) || errors.Is(err, ErrMessageTooLong) || errors.Is(err, ErrUnauthorized)
+}
+
+func (job *BroadcastJob[BOTDATA, USERDATA]) skip(n int) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	job.progress.Skipped += n
+}
+
+// reserve persists the cursor past the last of n targets before they are
+// sent to. A job that can't be persisted is paused rather than risk
+// duplicates.
+func (job *BroadcastJob[BOTDATA, USERDATA]) reserve(last int64, n int) bool {
+	job.mu.Lock()
+	previous := job.cursor
+	job.cursor = &last
+	job.reserved += n
+	job.mu.Unlock()
+
+	if err := job.save(); err != nil {
+		job.mu.Lock()
+		job.cursor = previous
+		job.reserved -= n
+		job.mu.Unlock()
+		job.Pause()
+		job.report()
+		return false
+	}
+	return true
+}
+
+// save persists the job. Uploads can only be persisted once they have a file
+// ID, so until the first delivery of one there is nothing to save.
+func (job *BroadcastJob[BOTDATA, USERDATA]) save() error {
+	if job.content.Media != "" && job.content.File.IsUpload() {
+		return nil
+	}
+	job.mu.Lock()
+	record := &StoredBroadcast{
+		ID:              job.id,
+		Text:            job.content.Text,
+		ParseMode:       job.content.ParseMode,
+		Media:           job.content.Media,
+		FileID:          job.content.File.fileID,
+		FileURL:         job.content.File.url,
+		Segment:         job.config.Segment,
+		Cursor:          job.cursor,
+		State:           job.progress.State,
+		Sent:            job.progress.Sent,
+		Failed:          job.progress.Failed,
+		Blocked:         job.progress.Blocked,
+		Skipped:         job.progress.Skipped,
+		Reserved:        job.reserved,
+		ReportChatID:    job.config.ReportChatID,
+		ReportMessageID: job.reportMessageID,
+		CreatedAt:       job.created,
+	}
+	job.mu.Unlock()
+	return job.client.Firebase.UpdateBroadcast(record)
+}
+
+// sendReport posts the status message that report keeps up to date.
+func (job *BroadcastJob[BOTDATA, USERDATA]) sendReport() {
+	if job.config.ReportChatID == 0 {
+		return
+	}
+	session := job.client.getSession(job.config.ReportChatID)
+	if session == nil {
+		return
+	}
+	if message, err := session.SendText(job.Progress().String()); err == nil {
+		job.reportMessageID = message.MessageID
+	}
+}
+
+func (job *BroadcastJob[BOTDATA, USERDATA]) report() {
+	if job.reportMessageID == 0 {
+		return
+	}
+	if session := job.client.getSession(job.config.ReportChatID); session != nil {
+		session.EditText(job.reportMessageID, job.Progress().String())
+	}
+}
+
+func (job *BroadcastJob[BOTDATA, USERDATA]) run() {
+	stopReports := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(broadcastReportInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopReports:
+				return
+			case <-ticker.C:
+				job.report()
+			}
+		}
+	}()
+
+	targets := job.targets
+	// Upload the file once, then send it to everyone else by file ID.
+	for len(targets) > 0 && job.content.Media != "" && job.content.File.IsUpload() && job.proceed() {
+		fileID, err := job.send(targets[0])
+		job.record(err)
+		job.mu.Lock()
+		job.cursor = &targets[0]
+		job.reserved++
+		job.mu.Unlock()
+		targets = targets[1:]
+		if fileID != "" {
+			job.content.File = FileFromID(fileID)
+			job.save()
+		}
+		// An upload Telegram rejects would be rejected for everyone else.
+		if isUploadRejected(err) {
+			job.fail(err, len(targets))
+		}
+	}
+
+	ids := make(chan int64)
+	var sends sync.WaitGroup
+	for i := 0; i < broadcastWorkers; i++ {
+		go func() {
+			for id := range ids {
+				_, err := job.send(id)
+				job.record(err)
+				sends.Done()
+			}
+		}()
+	}
+	for len(targets) > 0 && job.proceed() {
+		batch := targets[:min(broadcastBatchSize, len(targets))]
+		if !job.reserve(batch[len(batch)-1], len(batch)) {
+			continue
+		}
+		targets = targets[len(batch):]
+		for i, id := range batch {
+			if !job.proceed() {
+				job.skip(len(batch) - i)
+				break
+			}
+			sends.Add(1)
+			ids <- id
+		}
+		// Persist the counts of the finished batch, so that a resumed job
+		// only counts the batch in flight as skipped.
+		sends.Wait()
+		job.save()
+	}
+	close(ids)
+
+	close(stopReports)
+	job.mu.Lock()
+	if job.progress.State == BroadcastRunning {
+		job.progress.State = BroadcastDone
+	}
+	job.mu.Unlock()
+	job.client.Firebase.DeleteBroadcast(job.id)
+	job.client.removeBroadcastJob(job)
+	job.report()
+	close(job.done)
+}
+
+// send delivers the content to one session, returning the file ID of sent
+// media.
+func (job *BroadcastJob[BOTDATA, USERDATA]) send(id int64) (string, error) {
+	session := job.client.getSession(id)
+	if session == nil {
+		return "", ErrChatNotFound
+	}
+
+	// Broadcasts are bulk traffic, so that they leave room for replies.
+	ctx := bulkContext(context.Background())
+	content := job.content
+	config := MediaConfig{Caption: content.Text, ParseMode: content.ParseMode}
+	var fileID string
+	var err error
+	switch content.Media {
+	case InputMediaPhoto:
+		_, fileID, err = session.sendImage(ctx, content.File, config)
+	case InputMediaVideo:
+		_, fileID, err = session.sendVideo(ctx, content.File, nil, config)
+	case InputMediaDocument:
+		_, fileID, err = session.sendFile(ctx, content.File, config)
+	case InputMediaAudio:
+		_, fileID, err = session.sendAudio(ctx, content.File, config)
+	default:
+		_, err = session.sendText(ctx, content.Text, MessageConfig{ParseMode: content.ParseMode})
+	}
+	return fileID, err
+}
+
+// processBroadcastCommand handles /broadcast for admins:
+// "/broadcast [segment=<name>] <text>" starts a broadcast of text, or of the
+// message the command replies to, with text replacing its caption. "pause",
+// "resume", "cancel" and "status" control the latest unfinished one.
+// Progress is shown in a status message sent to the admin.
+func (c *Client[BOTDATA, USERDATA]) processBroadcastCommand(session *Session[BOTDATA, USERDATA], args string, message *Message) {
+	job := c.latestBroadcastJob()
+
+	args = strings.TrimSpace(args)
+	switch args {
+	case "pause", "resume", "cancel", "status":
+		if job == nil {
+			session.ReplyText("No broadcast is running.", message.MessageID)
+			return
+		}
+		switch args {
+		case "pause":
+			job.Pause()
+		case "resume":
+			job.Resume()
+		case "cancel":
+			job.Cancel()
+		}
+		session.ReplyText(job.Progress().String(), message.MessageID)
+		return
+	}
+
+	config := BroadcastConfig{ReportChatID: message.Chat.ID}
+	if name, ok := strings.CutPrefix(args, "segment="); ok {
+		config.Segment, args, _ = strings.Cut(name, " ")
+		args = strings.TrimSpace(args)
+	}
+	var content BroadcastContent
+	if message.ReplyToMessage != nil {
+		content = broadcastContentOf(message.ReplyToMessage)
+	}
+	if args != "" {
+		content.Text = args
+	}
+	if content.Text == "" && content.Media == "" {
+		session.ReplyText("Usage: /broadcast [segment=<name>] <text> | pause | resume | cancel | status\nReply to a photo, video, document or audio to broadcast it.", message.MessageID)
+		return
+	}
+
+	if job != nil {
+		session.ReplyText(ErrBroadcastRunning.Error(), message.MessageID)
+		return
+	}
+
+	if _, err := c.Broadcast(content, config); err != nil {
+		session.ReplyText(err.Error(), message.MessageID)
+	}
+}
+
+// broadcastContentOf returns the text or the media with its caption of
+// message, sent again by file ID.
+func broadcastContentOf(message *Message) BroadcastContent {
+	content := BroadcastContent{Text: message.Caption}
+	switch {
+	case message.LargestPhoto() != nil:
+		content.Media, content.File = InputMediaPhoto, FileFromID(message.LargestPhoto().FileID)
+	case message.Video != nil:
+		content.Media, content.File = InputMediaVideo, FileFromID(message.Video.FileID)
+	case message.Document != nil:
+		content.Media, content.File = InputMediaDocument, FileFromID(message.Document.FileID)
+	case message.Audio != nil:
+		content.Media, content.File = InputMediaAudio, FileFromID(message.Audio.FileID)
+	default:
+		content.Text = message.Text
+	}
+	return content
+}
@@ -0,0 +1,134 @@
+package tgbot
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// Telegram rejects callback data longer than 64 bytes.
+const maxCallbackDataLength = 64
+
+// Number of HMAC bytes kept in the signature; encodes to 8 characters.
+const callbackSignatureSize = 6
+
+const callbackSeparator = ":"
+
+var (
+	ErrCallbackDataTooLong = errors.New("callback data exceeds 64 bytes")
+	ErrInvalidCallbackData = errors.New("callback data contains a separator")
+)
+
+// CallbackData is the decoded form of a button created with
+// EncodeCallbackData. Route selects the handler registered with
+// RegisterCallbackHandler and Args carries the button's parameters.
+type CallbackData struct {
+	Route string
+	Args  []string
+}
+
+func (d CallbackData) Arg(i int) string {
+	if i < 0 || i >= len(d.Args) {
+		return ""
+	}
+	return d.Args[i]
+}
+
+type callbackCodec struct {
+	secret []byte
+}
+
+func newCallbackCodec(secret []byte, token string) *callbackCodec {
+	if len(secret) == 0 {
+		sum := sha256.Sum256([]byte("callback:" + token))
+		secret = sum[:]
+	}
+	return &callbackCodec{secret: secret}
+}
+
+func (cc *callbackCodec) sign(payload string) string {
+	mac := hmac.New(sha256.New, cc.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:callbackSignatureSize])
+}
+
+func (cc *callbackCodec) encode(route string, args ...string) (string, error) {
+	parts := make([]string, 0, len(args)+1)
+	parts = append(parts, route)
+	parts = append(parts, args...)
+	for _, part := range parts {
+		if strings.Contains(part, callbackSeparator) {
+			return "", ErrInvalidCallbackData
+		}
+	}
+
+	payload := strings.Join(parts, callbackSeparator)
+	data := payload + callbackSeparator + cc.sign(payload)
+	if len(data) > maxCallbackDataLength {
+		return "", ErrCallbackDataTooLong
+	}
+	return data, nil
+}
+
+func (cc *callbackCodec) decode(data string) (CallbackData, bool) {
+	i := strings.LastIndex(data, callbackSeparator)
+	if i <= 0 {
+		return CallbackData{}, false
+	}
+
+	payload, signature := data[:i], data[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(cc.sign(payload))) {
+		return CallbackData{}, false
+	}
+
+	parts := strings.Split(payload, callbackSeparator)
+	return CallbackData{Route: parts[0], Args: parts[1:]}, true
+}
+
+func (c *Client[BOTDATA, USERDATA]) EncodeCallbackData(route string, args ...string) (string, error) {
+	return c.callbackCodec.encode(route, args...)
+}
+
+func (c *Client[BOTDATA, USERDATA]) registerCallbackHandler(prefix string, handler func(*Session[BOTDATA, USERDATA], CallbackData, *CallbackQuery) *CallbackAnswer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Handlers.CallbackHandlers[prefix] = handler
+}
+
+// callbackHandler returns the handler registered under the longest prefix
+// of route.
+func (c *Client[BOTDATA, USERDATA]) callbackHandler(route string) func(*Session[BOTDATA, USERDATA], CallbackData, *CallbackQuery) *CallbackAnswer {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var handler func(*Session[BOTDATA, USERDATA], CallbackData, *CallbackQuery) *CallbackAnswer
+	matched := -1
+	for prefix, h := range c.Handlers.CallbackHandlers {
+		if strings.HasPrefix(route, prefix) && len(prefix) > matched {
+			handler = h
+			matched = len(prefix)
+		}
+	}
+	return handler
+}
+
+func (c *Client[BOTDATA, USERDATA]) handleRoutedCallback(session *Session[BOTDATA, USERDATA], query *CallbackQuery) bool {
+	if query == nil {
+		return false
+	}
+
+	data, ok := c.callbackCodec.decode(query.Data)
+	if !ok {
+		return false
+	}
+
+	handler := c.callbackHandler(data.Route)
+	if handler == nil {
+		return false
+	}
+
+	_ = session.answerCallbackQuery(query.ID, handler(session, data, query))
+	return true
+}
@@ -0,0 +1,84 @@
+package tgbot
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Telegram shows a chat action for 5 seconds, so it is repeated slightly
+// more often to keep the indicator from flickering.
+const chatActionInterval = 4 * time.Second
+
+type ChatAction string
+
+const (
+	ChatActionTyping          ChatAction = "typing"
+	ChatActionUploadPhoto     ChatAction = "upload_photo"
+	ChatActionRecordVideo     ChatAction = "record_video"
+	ChatActionUploadVideo     ChatAction = "upload_video"
+	ChatActionRecordVoice     ChatAction = "record_voice"
+	ChatActionUploadVoice     ChatAction = "upload_voice"
+	ChatActionUploadDocument  ChatAction = "upload_document"
+	ChatActionChooseSticker   ChatAction = "choose_sticker"
+	ChatActionFindLocation    ChatAction = "find_location"
+	ChatActionRecordVideoNote ChatAction = "record_video_note"
+	ChatActionUploadVideoNote ChatAction = "upload_video_note"
+)
+
+// StartChatAction shows action (such as "typing…") in the chat until the
+// returned function is called or the next message is sent to the session.
+// Starting another action replaces the current one.
+func (s *Session[BOTDATA, USERDATA]) StartChatAction(action ChatAction) func() {
+	// Cancelling the context also abandons a request still in flight, so a
+	// fast send can't be followed by a stale indicator.
+	ctx, stop := context.WithCancel(context.Background())
+
+	s.actionMu.Lock()
+	if s.actionStop != nil {
+		s.actionStop()
+	}
+	s.actionStop = stop
+	s.actionMu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(chatActionInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+			err := s.client.bot.SendChatAction(ctx, s.ID, action)
+			if errors.Is(err, ErrForbidden) || errors.Is(err, ErrChatNotFound) {
+				stop()
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return stop
+}
+
+func (s *Session[BOTDATA, USERDATA]) stopChatAction() {
+	s.actionMu.Lock()
+	defer s.actionMu.Unlock()
+	if s.actionStop != nil {
+		s.actionStop()
+		s.actionStop = nil
+	}
+}
+
+// uploading shows action while file is uploaded; the returned function ends
+// it. Files sent by ID or URL need no indicator.
+func (s *Session[BOTDATA, USERDATA]) uploading(file InputFile, action ChatAction) func() {
+	if !file.IsUpload() {
+		return func() {}
+	}
+	return s.StartChatAction(action)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	firebase "firebase.google.com/go/v4"
 	"google.golang.org/api/option"
@@ -17,13 +18,45 @@ type ClientDelegate[BOTDATA any, USERDATA any] interface {
 	DidLoadPreference()
 }
 
+// BlockedStateDelegate can be implemented by a ClientDelegate to be told when
+// a user blocks or unblocks the bot.
+type BlockedStateDelegate[BOTDATA any, USERDATA any] interface {
+	DidChangeBlocked(*Session[BOTDATA, USERDATA], bool)
+}
+
 type Handlers[BOTDATA any, USERDATA any] struct {
-	TextHandler     func(*Session[BOTDATA, USERDATA], string, *Message)
-	CommandHandlers map[string]func(*Session[BOTDATA, USERDATA], string, *Message) CmdResult
+	TextHandler      func(*Session[BOTDATA, USERDATA], string, *Message)
+	CommandHandlers  map[string]func(*Session[BOTDATA, USERDATA], string, *Message) CmdResult
+	PhotoHandler     func(*Session[BOTDATA, USERDATA], []PhotoSize, *Message)
+	DocumentHandler  func(*Session[BOTDATA, USERDATA], *Document, *Message)
+	VoiceHandler     func(*Session[BOTDATA, USERDATA], *Voice, *Message)
+	VideoHandler     func(*Session[BOTDATA, USERDATA], *Video, *Message)
+	AudioHandler     func(*Session[BOTDATA, USERDATA], *Audio, *Message)
+	StickerHandler   func(*Session[BOTDATA, USERDATA], *Sticker, *Message)
+	ContactHandler   func(*Session[BOTDATA, USERDATA], *Contact, *Message)
+	LocationHandler  func(*Session[BOTDATA, USERDATA], *Location, *Message)
+	VideoNoteHandler func(*Session[BOTDATA, USERDATA], *VideoNote, *Message)
+	PollHandler      func(*Session[BOTDATA, USERDATA], *Poll, *Message)
+	DiceHandler      func(*Session[BOTDATA, USERDATA], *Dice, *Message)
+
+	EditedMessageHandler     func(*Session[BOTDATA, USERDATA], *Message)
+	ChannelPostHandler       func(*Session[BOTDATA, USERDATA], *Message)
+	EditedChannelPostHandler func(*Session[BOTDATA, USERDATA], *Message)
+	MyChatMemberHandler      func(*Session[BOTDATA, USERDATA], *ChatMemberUpdated)
+	ChatMemberHandler        func(*Session[BOTDATA, USERDATA], *ChatMemberUpdated)
+	ChatJoinRequestHandler   func(*Session[BOTDATA, USERDATA], *ChatJoinRequest)
+	PollAnswerHandler        func(*Session[BOTDATA, USERDATA], *PollAnswer)
+	MessageReactionHandler   func(*Session[BOTDATA, USERDATA], *MessageReaction)
+
+	InlineQueryHandler        func(*Session[BOTDATA, USERDATA], *InlineQuery) *InlineQueryAnswer
+	ChosenInlineResultHandler func(*Session[BOTDATA, USERDATA], *ChosenInlineResult)
+
+	CallbackHandlers map[string]func(*Session[BOTDATA, USERDATA], CallbackData, *CallbackQuery) *CallbackAnswer
 }
 
 type Client[BOTDATA any, USERDATA any] struct {
 	bot         *botImpl
+	me          *BotIdentity
 	Firebase    Firebase[BOTDATA, USERDATA]
 	Preference  Preference[BOTDATA]
 	Sessions    map[int64]*Session[BOTDATA, USERDATA]
@@ -32,15 +65,40 @@ type Client[BOTDATA any, USERDATA any] struct {
 	delegate    ClientDelegate[BOTDATA, USERDATA]
 	globalQueue *DispatchQueue
 
-	queryStore *queryStore[BOTDATA, USERDATA]
+	queryStore       *queryStore[BOTDATA, USERDATA]
+	queryHandlers    map[string]func(*Session[BOTDATA, USERDATA], string) *CallbackAnswer
+	persistQueries   bool
+	queryTTL         time.Duration
+	expiredQueryText string
+	menuMu           sync.RWMutex // handlers send menus while mu is held
+	menus            map[string]*Menu[BOTDATA, USERDATA]
+	callbackCodec    *callbackCodec
+
+	broadcastSegments map[string]func(*Session[BOTDATA, USERDATA]) bool
+	broadcastMu       sync.Mutex
+	broadcastJobs     map[string]*BroadcastJob[BOTDATA, USERDATA]
 }
 
 type pendingQuery[BOTDATA any, USERDATA any] struct {
-	sessionID int64
-	answers   map[string]string
-	handler   func(*Session[BOTDATA, USERDATA], string)
+	sessionID     int64
+	answers       map[string]string
+	labels        map[string]string
+	layout        [][]string
+	multiSelect   bool
+	selected      map[string]bool
+	doneLabel     string
+	otherLabel    string
+	otherPrompt   string
+	handlerName   string
+	handler       func(*Session[BOTDATA, USERDATA], string) *CallbackAnswer
+	answerHandler func(*Session[BOTDATA, USERDATA], QueryAnswer) *CallbackAnswer
 }
 
+const (
+	defaultQueryTTL         = 24 * time.Hour
+	defaultExpiredQueryText = "This option has expired."
+)
+
 func (c *Client[BOTDATA, USERDATA]) Bot() BotAPI {
 	return c.bot
 }
@@ -49,16 +107,32 @@ func newClient[BOTDATA any, USERDATA any](config Config, delegate ClientDelegate
 	client := &Client[BOTDATA, USERDATA]{
 		Sessions: make(map[int64]*Session[BOTDATA, USERDATA]),
 		Handlers: Handlers[BOTDATA, USERDATA]{
-			CommandHandlers: make(map[string]func(*Session[BOTDATA, USERDATA], string, *Message) CmdResult),
+			CommandHandlers:  make(map[string]func(*Session[BOTDATA, USERDATA], string, *Message) CmdResult),
+			CallbackHandlers: make(map[string]func(*Session[BOTDATA, USERDATA], CallbackData, *CallbackQuery) *CallbackAnswer),
 		},
-		delegate:    delegate,
-		globalQueue: newDispatchQueue(1, 100),
-		queryStore:  newQueryStore[BOTDATA, USERDATA](5),
+		delegate:         delegate,
+		globalQueue:      newDispatchQueue(1, 100),
+		queryStore:       newQueryStore[BOTDATA, USERDATA](5),
+		queryHandlers:    make(map[string]func(*Session[BOTDATA, USERDATA], string) *CallbackAnswer),
+		persistQueries:   config.PersistQueries,
+		queryTTL:         config.QueryTTL,
+		expiredQueryText: config.ExpiredQueryText,
+		menus:            make(map[string]*Menu[BOTDATA, USERDATA]),
+		callbackCodec:    newCallbackCodec(config.CallbackSecret, config.TelegramBotToken),
+
+		broadcastSegments: make(map[string]func(*Session[BOTDATA, USERDATA]) bool),
+		broadcastJobs:     make(map[string]*BroadcastJob[BOTDATA, USERDATA]),
+	}
+	if client.queryTTL <= 0 {
+		client.queryTTL = defaultQueryTTL
+	}
+	if client.expiredQueryText == "" {
+		client.expiredQueryText = defaultExpiredQueryText
 	}
 
 	client.globalQueue.SetProcessHandler(client.processUpdate)
 
-	if err := client.initBot(config.TelegramBotToken); err != nil {
+	if err := client.initBot(config.TelegramBotToken, config.RateLimit, config.Retry); err != nil {
 		return nil, err
 	}
 
@@ -99,8 +173,8 @@ func (c *Client[BOTDATA, USERDATA]) initFirebase(credential []byte, databaseURL
 	return nil
 }
 
-func (c *Client[BOTDATA, USERDATA]) initBot(token string) error {
-	bi, err := newBotImpl(token, func(u *Update) {
+func (c *Client[BOTDATA, USERDATA]) initBot(token string, limits RateLimitConfig, retry RetryPolicy) error {
+	bi, err := newBotImpl(token, limits, retry, func(u *Update) {
 		c.globalQueue.Enqueue(u)
 	})
 	if err != nil {
@@ -110,7 +184,17 @@ func (c *Client[BOTDATA, USERDATA]) initBot(token string) error {
 	return nil
 }
 
+func (c *Client[BOTDATA, USERDATA]) Me() *BotIdentity {
+	return c.me
+}
+
 func (c *Client[BOTDATA, USERDATA]) start() error {
+	me, err := c.bot.GetMe(context.Background())
+	if err != nil {
+		return err
+	}
+	c.me = me
+
 	users, err := c.Firebase.GetUsers()
 	if err != nil {
 		return err
@@ -125,10 +209,15 @@ func (c *Client[BOTDATA, USERDATA]) start() error {
 
 	c.reload()
 
+	if err := c.resumeBroadcasts(); err != nil {
+		return err
+	}
+
 	c.globalQueue.Start()
 	ctx, cancel := context.WithCancel(context.Background())
 	c.bot.setCancel(cancel)
 	go c.bot.Start(ctx)
+
 	return nil
 }
 
@@ -169,6 +258,132 @@ func (c *Client[BOTDATA, USERDATA]) registerCommandHandler(cmd string, handler f
 	c.Handlers.CommandHandlers[cmd] = handler
 }
 
+func (c *Client[BOTDATA, USERDATA]) registerPhotoHandler(handler func(*Session[BOTDATA, USERDATA], []PhotoSize, *Message)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Handlers.PhotoHandler = handler
+}
+
+func (c *Client[BOTDATA, USERDATA]) registerDocumentHandler(handler func(*Session[BOTDATA, USERDATA], *Document, *Message)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Handlers.DocumentHandler = handler
+}
+
+func (c *Client[BOTDATA, USERDATA]) registerVoiceHandler(handler func(*Session[BOTDATA, USERDATA], *Voice, *Message)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Handlers.VoiceHandler = handler
+}
+
+func (c *Client[BOTDATA, USERDATA]) registerVideoHandler(handler func(*Session[BOTDATA, USERDATA], *Video, *Message)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Handlers.VideoHandler = handler
+}
+
+func (c *Client[BOTDATA, USERDATA]) registerAudioHandler(handler func(*Session[BOTDATA, USERDATA], *Audio, *Message)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Handlers.AudioHandler = handler
+}
+
+func (c *Client[BOTDATA, USERDATA]) registerStickerHandler(handler func(*Session[BOTDATA, USERDATA], *Sticker, *Message)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Handlers.StickerHandler = handler
+}
+
+func (c *Client[BOTDATA, USERDATA]) registerContactHandler(handler func(*Session[BOTDATA, USERDATA], *Contact, *Message)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Handlers.ContactHandler = handler
+}
+
+func (c *Client[BOTDATA, USERDATA]) registerLocationHandler(handler func(*Session[BOTDATA, USERDATA], *Location, *Message)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Handlers.LocationHandler = handler
+}
+
+func (c *Client[BOTDATA, USERDATA]) registerVideoNoteHandler(handler func(*Session[BOTDATA, USERDATA], *VideoNote, *Message)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Handlers.VideoNoteHandler = handler
+}
+
+func (c *Client[BOTDATA, USERDATA]) registerPollHandler(handler func(*Session[BOTDATA, USERDATA], *Poll, *Message)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Handlers.PollHandler = handler
+}
+
+func (c *Client[BOTDATA, USERDATA]) registerDiceHandler(handler func(*Session[BOTDATA, USERDATA], *Dice, *Message)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Handlers.DiceHandler = handler
+}
+
+func (c *Client[BOTDATA, USERDATA]) registerEditedMessageHandler(handler func(*Session[BOTDATA, USERDATA], *Message)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Handlers.EditedMessageHandler = handler
+}
+
+func (c *Client[BOTDATA, USERDATA]) registerChannelPostHandler(handler func(*Session[BOTDATA, USERDATA], *Message)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Handlers.ChannelPostHandler = handler
+}
+
+func (c *Client[BOTDATA, USERDATA]) registerEditedChannelPostHandler(handler func(*Session[BOTDATA, USERDATA], *Message)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Handlers.EditedChannelPostHandler = handler
+}
+
+func (c *Client[BOTDATA, USERDATA]) registerMyChatMemberHandler(handler func(*Session[BOTDATA, USERDATA], *ChatMemberUpdated)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Handlers.MyChatMemberHandler = handler
+}
+
+func (c *Client[BOTDATA, USERDATA]) registerChatMemberHandler(handler func(*Session[BOTDATA, USERDATA], *ChatMemberUpdated)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Handlers.ChatMemberHandler = handler
+}
+
+func (c *Client[BOTDATA, USERDATA]) registerChatJoinRequestHandler(handler func(*Session[BOTDATA, USERDATA], *ChatJoinRequest)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Handlers.ChatJoinRequestHandler = handler
+}
+
+func (c *Client[BOTDATA, USERDATA]) registerPollAnswerHandler(handler func(*Session[BOTDATA, USERDATA], *PollAnswer)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Handlers.PollAnswerHandler = handler
+}
+
+func (c *Client[BOTDATA, USERDATA]) registerMessageReactionHandler(handler func(*Session[BOTDATA, USERDATA], *MessageReaction)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Handlers.MessageReactionHandler = handler
+}
+
+func (c *Client[BOTDATA, USERDATA]) registerInlineQueryHandler(handler func(*Session[BOTDATA, USERDATA], *InlineQuery) *InlineQueryAnswer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Handlers.InlineQueryHandler = handler
+}
+
+func (c *Client[BOTDATA, USERDATA]) registerChosenInlineResultHandler(handler func(*Session[BOTDATA, USERDATA], *ChosenInlineResult)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Handlers.ChosenInlineResultHandler = handler
+}
+
 func (c *Client[BOTDATA, USERDATA]) getSession(id int64) *Session[BOTDATA, USERDATA] {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -197,56 +412,123 @@ func (c *Client[BOTDATA, USERDATA]) processUpdate(update *Update) {
 			ID:       id,
 			UserData: c.delegate.NewUserData(),
 		}
-		if err := c.Firebase.UpdateUser(user); err != nil {
-			return
-		}
-
 		session = newSession(user, c)
-		c.insertSession(session)
 
-		c.delegate.DidLoadUser(session, user)
-	}
+		// Only chats that talk to the bot become users. Others, such as
+		// inline queries or poll answers from people who never started it,
+		// get a session that is neither stored nor kept.
+		if createsSession(update) {
+			if err := c.Firebase.UpdateUser(user); err != nil {
+				return
+			}
+			c.insertSession(session)
 
-	if update.CallbackQuery != nil {
-		c.processCallbackQuery(session, update.CallbackQuery)
-		return
+			c.delegate.DidLoadUser(session, user)
+		}
 	}
 
-	if update.Message != nil {
+	switch {
+	case update.CallbackQuery != nil:
+		c.processCallbackQuery(session, update.CallbackQuery)
+	case update.Message != nil:
 		c.processMessage(session, update.Message)
+	case update.EditedMessage != nil:
+		c.processEditedMessage(session, update.EditedMessage)
+	case update.ChannelPost != nil:
+		c.processChannelPost(session, update.ChannelPost)
+	case update.EditedChannelPost != nil:
+		c.processEditedChannelPost(session, update.EditedChannelPost)
+	case update.MyChatMember != nil:
+		c.processMyChatMember(session, update.MyChatMember)
+	case update.ChatMember != nil:
+		c.processChatMember(session, update.ChatMember)
+	case update.ChatJoinRequest != nil:
+		c.processChatJoinRequest(session, update.ChatJoinRequest)
+	case update.PollAnswer != nil:
+		c.processPollAnswer(session, update.PollAnswer)
+	case update.MessageReaction != nil:
+		c.processMessageReaction(session, update.MessageReaction)
+	case update.InlineQuery != nil:
+		c.processInlineQuery(session, update.InlineQuery)
+	case update.ChosenInlineResult != nil:
+		c.processChosenInlineResult(session, update.ChosenInlineResult)
 	}
 }
 
+func createsSession(update *Update) bool {
+	return update.Message != nil || update.ChannelPost != nil || update.CallbackQuery != nil || update.ChatJoinRequest != nil
+}
+
 func (c *Client[BOTDATA, USERDATA]) sessionIDFromUpdate(update *Update) (int64, bool) {
-	if update.Message != nil {
+	switch {
+	case update.Message != nil:
 		return update.Message.Chat.ID, true
-	}
-	if update.CallbackQuery != nil {
+	case update.EditedMessage != nil:
+		return update.EditedMessage.Chat.ID, true
+	case update.ChannelPost != nil:
+		return update.ChannelPost.Chat.ID, true
+	case update.EditedChannelPost != nil:
+		return update.EditedChannelPost.Chat.ID, true
+	case update.CallbackQuery != nil:
 		if update.CallbackQuery.Message != nil {
 			return update.CallbackQuery.Message.Chat.ID, true
 		}
 		if update.CallbackQuery.From != nil {
 			return update.CallbackQuery.From.ID, true
 		}
+	case update.MyChatMember != nil:
+		return update.MyChatMember.Chat.ID, true
+	case update.ChatMember != nil:
+		return update.ChatMember.Chat.ID, true
+	case update.ChatJoinRequest != nil:
+		return update.ChatJoinRequest.Chat.ID, true
+	case update.PollAnswer != nil:
+		if update.PollAnswer.VoterChat != nil {
+			return update.PollAnswer.VoterChat.ID, true
+		}
+		if update.PollAnswer.User != nil {
+			return update.PollAnswer.User.ID, true
+		}
+	case update.MessageReaction != nil:
+		return update.MessageReaction.Chat.ID, true
+	case update.InlineQuery != nil:
+		if update.InlineQuery.From != nil {
+			return update.InlineQuery.From.ID, true
+		}
+	case update.ChosenInlineResult != nil:
+		if update.ChosenInlineResult.From != nil {
+			return update.ChosenInlineResult.From.ID, true
+		}
 	}
 	return 0, false
 }
 
 func (c *Client[BOTDATA, USERDATA]) processMessage(session *Session[BOTDATA, USERDATA], message *Message) {
-	if session.User.Blocked {
-		session.User.Blocked = false
-		c.Firebase.UpdateUser(session.User)
-	}
+	c.setBlocked(session, false)
 
 	if message.IsCommand() {
+		if !c.isAddressedToMe(message) {
+			return
+		}
+		session.awaitReply(nil, nil)
 		c.processCommand(session, message.Command(), message.CommandArguments(), message)
+	} else if handler := session.takeReplyHandler(message); handler != nil {
+		handler(session, message)
 	} else if session.CommandSession.Command != "" {
 		c.processCommand(session, session.CommandSession.Command, message.Text, message)
-	} else {
+	} else if !message.HasMedia() || !c.processMedia(session, message) {
 		c.processText(session, message.Text, message)
 	}
 }
 
+func (c *Client[BOTDATA, USERDATA]) isAddressedToMe(message *Message) bool {
+	target := message.CommandTarget()
+	if target == "" {
+		return true
+	}
+	return c.me != nil && strings.EqualFold(target, c.me.Username)
+}
+
 func (c *Client[BOTDATA, USERDATA]) processCommand(session *Session[BOTDATA, USERDATA], command string, args string, message *Message) {
 	switch command {
 	case CmdStart:
@@ -266,6 +548,17 @@ func (c *Client[BOTDATA, USERDATA]) processCommand(session *Session[BOTDATA, USE
 			session.ReplyText(fmt.Sprintf("Total Users: %d", len(c.Sessions)), message.MessageID)
 		}
 		return
+	case CmdBroadcast:
+		// A handler registered for the command replaces the built-in one.
+		c.mu.RLock()
+		_, registered := c.Handlers.CommandHandlers[CmdBroadcast]
+		c.mu.RUnlock()
+		if !registered {
+			if _, rv := c.Preference.Admins[message.Chat.ID]; rv {
+				c.processBroadcastCommand(session, args, message)
+			}
+			return
+		}
 	}
 
 	if c.Preference.OnlyAdminsCanCommandInGroup && (message.Chat.IsSuperGroup() || message.Chat.IsGroup()) {
@@ -324,19 +617,298 @@ func (c *Client[BOTDATA, USERDATA]) processText(session *Session[BOTDATA, USERDA
 	}
 }
 
-func (c *Client[BOTDATA, USERDATA]) processCallbackQuery(session *Session[BOTDATA, USERDATA], query *CallbackQuery) {
-	if session.User.Blocked {
-		session.User.Blocked = false
-		c.Firebase.UpdateUser(session.User)
+// processMedia reports whether a handler was registered for the media in
+// message; unhandled media goes to the text handler like it used to.
+func (c *Client[BOTDATA, USERDATA]) processMedia(session *Session[BOTDATA, USERDATA], message *Message) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	switch {
+	case len(message.Photo) > 0:
+		if handler := c.Handlers.PhotoHandler; handler != nil {
+			handler(session, message.Photo, message)
+			return true
+		}
+	case message.Document != nil:
+		if handler := c.Handlers.DocumentHandler; handler != nil {
+			handler(session, message.Document, message)
+			return true
+		}
+	case message.Voice != nil:
+		if handler := c.Handlers.VoiceHandler; handler != nil {
+			handler(session, message.Voice, message)
+			return true
+		}
+	case message.Video != nil:
+		if handler := c.Handlers.VideoHandler; handler != nil {
+			handler(session, message.Video, message)
+			return true
+		}
+	case message.Audio != nil:
+		if handler := c.Handlers.AudioHandler; handler != nil {
+			handler(session, message.Audio, message)
+			return true
+		}
+	case message.Sticker != nil:
+		if handler := c.Handlers.StickerHandler; handler != nil {
+			handler(session, message.Sticker, message)
+			return true
+		}
+	case message.Contact != nil:
+		if handler := c.Handlers.ContactHandler; handler != nil {
+			handler(session, message.Contact, message)
+			return true
+		}
+	case message.Location != nil:
+		if handler := c.Handlers.LocationHandler; handler != nil {
+			handler(session, message.Location, message)
+			return true
+		}
+	case message.VideoNote != nil:
+		if handler := c.Handlers.VideoNoteHandler; handler != nil {
+			handler(session, message.VideoNote, message)
+			return true
+		}
+	case message.Poll != nil:
+		if handler := c.Handlers.PollHandler; handler != nil {
+			handler(session, message.Poll, message)
+			return true
+		}
+	case message.Dice != nil:
+		if handler := c.Handlers.DiceHandler; handler != nil {
+			handler(session, message.Dice, message)
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Client[BOTDATA, USERDATA]) processEditedMessage(session *Session[BOTDATA, USERDATA], message *Message) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if handler := c.Handlers.EditedMessageHandler; handler != nil {
+		handler(session, message)
+	}
+}
+
+func (c *Client[BOTDATA, USERDATA]) processChannelPost(session *Session[BOTDATA, USERDATA], message *Message) {
+	c.mu.RLock()
+	handler := c.Handlers.ChannelPostHandler
+	c.mu.RUnlock()
+
+	// Channel posts used to be delivered as regular messages; keep doing so
+	// for bots that have not opted into a dedicated handler.
+	if handler == nil {
+		c.processMessage(session, message)
+		return
+	}
+	handler(session, message)
+}
+
+func (c *Client[BOTDATA, USERDATA]) processEditedChannelPost(session *Session[BOTDATA, USERDATA], message *Message) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if handler := c.Handlers.EditedChannelPostHandler; handler != nil {
+		handler(session, message)
+	}
+}
+
+func (c *Client[BOTDATA, USERDATA]) processMyChatMember(session *Session[BOTDATA, USERDATA], update *ChatMemberUpdated) {
+	// The bot can no longer reach a chat once it has been blocked by the
+	// user or removed from the group, and can again once re-added.
+	c.setBlocked(session, !update.NewChatMember.IsPresent())
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if handler := c.Handlers.MyChatMemberHandler; handler != nil {
+		handler(session, update)
 	}
+}
+
+func (c *Client[BOTDATA, USERDATA]) processInlineQuery(session *Session[BOTDATA, USERDATA], query *InlineQuery) {
+	c.mu.RLock()
+	handler := c.Handlers.InlineQueryHandler
+	c.mu.RUnlock()
+
+	if handler == nil {
+		return
+	}
+
+	answer := handler(session, query)
+	if answer == nil {
+		return
+	}
+	if answer.NextOffset == "" && len(answer.Results) > maxInlineQueryResults {
+		answer.Results, answer.NextOffset = PaginateInlineResults(answer.Results, query.Offset, maxInlineQueryResults)
+	}
+	_ = c.bot.answerInlineQuery(context.Background(), query.ID, answer)
+}
+
+func (c *Client[BOTDATA, USERDATA]) processChosenInlineResult(session *Session[BOTDATA, USERDATA], result *ChosenInlineResult) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if handler := c.Handlers.ChosenInlineResultHandler; handler != nil {
+		handler(session, result)
+	}
+}
+
+func (c *Client[BOTDATA, USERDATA]) setBlocked(session *Session[BOTDATA, USERDATA], blocked bool) {
+	// Sessions that aren't kept have no user to update.
+	if c.getSession(session.ID) != session {
+		return
+	}
+
+	session.blockedMu.Lock()
+	if session.User.Blocked == blocked {
+		session.blockedMu.Unlock()
+		return
+	}
+	session.User.Blocked = blocked
+	if blocked {
+		session.User.BlockedAt = time.Now()
+	} else {
+		session.User.UnblockedAt = time.Now()
+	}
+	c.Firebase.UpdateUser(session.User)
+	session.blockedMu.Unlock()
+
+	if delegate, ok := c.delegate.(BlockedStateDelegate[BOTDATA, USERDATA]); ok {
+		delegate.DidChangeBlocked(session, blocked)
+	}
+}
+
+func (c *Client[BOTDATA, USERDATA]) processChatMember(session *Session[BOTDATA, USERDATA], update *ChatMemberUpdated) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if handler := c.Handlers.ChatMemberHandler; handler != nil {
+		handler(session, update)
+	}
+}
+
+func (c *Client[BOTDATA, USERDATA]) processChatJoinRequest(session *Session[BOTDATA, USERDATA], request *ChatJoinRequest) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if handler := c.Handlers.ChatJoinRequestHandler; handler != nil {
+		handler(session, request)
+	}
+}
+
+func (c *Client[BOTDATA, USERDATA]) processPollAnswer(session *Session[BOTDATA, USERDATA], answer *PollAnswer) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if handler := c.Handlers.PollAnswerHandler; handler != nil {
+		handler(session, answer)
+	}
+}
+
+func (c *Client[BOTDATA, USERDATA]) processMessageReaction(session *Session[BOTDATA, USERDATA], reaction *MessageReaction) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if handler := c.Handlers.MessageReactionHandler; handler != nil {
+		handler(session, reaction)
+	}
+}
+
+func (c *Client[BOTDATA, USERDATA]) processCallbackQuery(session *Session[BOTDATA, USERDATA], query *CallbackQuery) {
+	c.setBlocked(session, false)
 
 	if c.handlePendingQueryCallback(session, query) {
 		return
 	}
+
+	if c.handleMenuCallback(session, query) {
+		return
+	}
+
+	if c.handleRoutedCallback(session, query) {
+		return
+	}
+
+	// Leaving a callback unanswered keeps the client's spinner running, so
+	// tell the user the button is stale instead.
+	_ = c.bot.answerCallbackQuery(context.Background(), query.ID, &CallbackAnswer{Text: c.expiredQueryText})
+}
+
+func (c *Client[BOTDATA, USERDATA]) registerQueryHandler(name string, handler func(*Session[BOTDATA, USERDATA], string) *CallbackAnswer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.queryHandlers[name] = handler
 }
 
-func (c *Client[BOTDATA, USERDATA]) createPendingQuery(sessionID int64, options []string, handler func(*Session[BOTDATA, USERDATA], string)) *InlineKeyboardMarkup {
-	return c.queryStore.Create(sessionID, options, handler)
+func (c *Client[BOTDATA, USERDATA]) getQueryHandler(name string) func(*Session[BOTDATA, USERDATA], string) *CallbackAnswer {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.queryHandlers[name]
+}
+
+func (c *Client[BOTDATA, USERDATA]) createPendingQuery(sessionID int64, options []string, handler func(*Session[BOTDATA, USERDATA], string) *CallbackAnswer) *InlineKeyboardMarkup {
+	item := newPendingQuery[BOTDATA, USERDATA](sessionID, options)
+	item.handler = handler
+	_, markup := c.queryStore.Create(item)
+	return markup
+}
+
+func (c *Client[BOTDATA, USERDATA]) createNamedQuery(sessionID int64, options []string, handlerName string) (*InlineKeyboardMarkup, error) {
+	handler := c.getQueryHandler(handlerName)
+	if handler == nil {
+		return nil, ErrUnknownQueryHandler
+	}
+
+	item := newPendingQuery[BOTDATA, USERDATA](sessionID, options)
+	item.handlerName = handlerName
+	item.handler = handler
+	queryID, markup := c.queryStore.Create(item)
+	if markup == nil || !c.persistQueries {
+		return markup, nil
+	}
+
+	err := c.Firebase.UpdateQuery(&StoredQuery{
+		ID:        queryID,
+		SessionID: sessionID,
+		Answers:   queryAnswers(options),
+		Handler:   handlerName,
+		ExpiresAt: time.Now().Add(c.queryTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return markup, nil
+}
+
+func (c *Client[BOTDATA, USERDATA]) loadPersistedQuery(queryID string) (pendingQuery[BOTDATA, USERDATA], bool) {
+	var zero pendingQuery[BOTDATA, USERDATA]
+	if !c.persistQueries {
+		return zero, false
+	}
+
+	stored, err := c.Firebase.GetQuery(queryID)
+	if err != nil || stored == nil {
+		return zero, false
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		c.Firebase.DeleteQuery(queryID)
+		return zero, false
+	}
+
+	handler := c.getQueryHandler(stored.Handler)
+	if handler == nil {
+		return zero, false
+	}
+
+	return pendingQuery[BOTDATA, USERDATA]{
+		sessionID:   stored.SessionID,
+		answers:     stored.Answers,
+		handlerName: stored.Handler,
+		handler:     handler,
+	}, true
 }
 
 func (c *Client[BOTDATA, USERDATA]) handlePendingQueryCallback(session *Session[BOTDATA, USERDATA], query *CallbackQuery) bool {
@@ -352,17 +924,60 @@ func (c *Client[BOTDATA, USERDATA]) handlePendingQueryCallback(session *Session[
 	queryID := parts[1]
 	answerKey := parts[2]
 
-	pending, ok := c.queryStore.Take(queryID)
+	pending, ok := c.queryStore.Get(queryID)
+	if !ok {
+		pending, ok = c.loadPersistedQuery(queryID)
+	}
 	if !ok || pending.sessionID != session.ID {
 		return false
 	}
 
+	if pending.multiSelect && answerKey != queryDoneKey && answerKey != queryOtherKey {
+		if _, ok := pending.answers[answerKey]; !ok {
+			return false
+		}
+		pending, ok = c.queryStore.Toggle(queryID, answerKey)
+		if !ok {
+			return false
+		}
+		_ = session.answerCallbackQuery(query.ID, nil)
+		if query.Message != nil {
+			session.EditReplyMarkup(query.Message.MessageID, pending.markup(queryID))
+		}
+		return true
+	}
+
+	// A forged or stale key must not consume the query.
 	answer, ok := pending.answers[answerKey]
+	switch answerKey {
+	case queryDoneKey:
+		ok = pending.multiSelect
+	case queryOtherKey:
+		ok = pending.otherLabel != ""
+	}
 	if !ok {
 		return false
 	}
 
-	_ = session.answerCallbackQuery(query.ID)
-	pending.handler(session, answer)
+	c.queryStore.Take(queryID)
+	if c.persistQueries && pending.handlerName != "" {
+		c.Firebase.DeleteQuery(queryID)
+	}
+
+	switch answerKey {
+	case queryDoneKey:
+		_ = session.answerCallbackQuery(query.ID, pending.deliver(session, QueryAnswer{Values: pending.selectedValues()}))
+	case queryOtherKey:
+		_ = session.answerCallbackQuery(query.ID, nil)
+		prompt := pending.otherPrompt
+		if prompt == "" {
+			prompt = pending.otherLabel
+		}
+		session.Ask(prompt, "", func(session *Session[BOTDATA, USERDATA], message *Message) {
+			pending.deliver(session, QueryAnswer{Text: message.Text, Other: true})
+		})
+	default:
+		_ = session.answerCallbackQuery(query.ID, pending.deliver(session, QueryAnswer{Values: []string{answer}}))
+	}
 	return true
 }
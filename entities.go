@@ -0,0 +1,105 @@
+package tgbot
+
+import "unicode/utf16"
+
+type Mention struct {
+	Username string
+	UserID   int64
+}
+
+func entityText(text string, e MessageEntity) string {
+	units := utf16.Encode([]rune(text))
+	start, end := e.Offset, e.Offset+e.Length
+	if start < 0 || end > len(units) || start > end {
+		return ""
+	}
+	return string(utf16.Decode(units[start:end]))
+}
+
+func textAfterEntity(text string, e MessageEntity) string {
+	units := utf16.Encode([]rune(text))
+	end := e.Offset + e.Length
+	if end < 0 || end > len(units) {
+		return ""
+	}
+	return string(utf16.Decode(units[end:]))
+}
+
+// EntityText returns the substring of Text or Caption covered by e,
+// depending on which of the two the entity was reported for.
+func (m *Message) EntityText(e MessageEntity) string {
+	for _, ce := range m.CaptionEntities {
+		if ce == e {
+			return entityText(m.Caption, e)
+		}
+	}
+	return entityText(m.Text, e)
+}
+
+// EntitiesOfType returns the entities of the given types found in both the
+// text and the caption of the message.
+func (m *Message) EntitiesOfType(types ...MessageEntityType) []MessageEntity {
+	entities := make([]MessageEntity, 0)
+	for _, list := range [][]MessageEntity{m.Entities, m.CaptionEntities} {
+		for _, e := range list {
+			for _, t := range types {
+				if e.Type == t {
+					entities = append(entities, e)
+					break
+				}
+			}
+		}
+	}
+	return entities
+}
+
+func (m *Message) Mentions() []Mention {
+	mentions := make([]Mention, 0)
+	m.eachEntity(func(source string, e MessageEntity) {
+		switch e.Type {
+		case EntityMention:
+			username := entityText(source, e)
+			if len(username) > 0 && username[0] == '@' {
+				username = username[1:]
+			}
+			mentions = append(mentions, Mention{Username: username})
+		case EntityTextMention:
+			if e.User != nil {
+				mentions = append(mentions, Mention{UserID: e.User.ID})
+			}
+		}
+	})
+	return mentions
+}
+
+func (m *Message) Links() []string {
+	links := make([]string, 0)
+	m.eachEntity(func(source string, e MessageEntity) {
+		switch e.Type {
+		case EntityURL:
+			links = append(links, entityText(source, e))
+		case EntityTextLink:
+			links = append(links, e.URL)
+		}
+	})
+	return links
+}
+
+func (m *Message) Hashtags() []string {
+	hashtags := make([]string, 0)
+	m.eachEntity(func(source string, e MessageEntity) {
+		if e.Type == EntityHashtag {
+			hashtags = append(hashtags, entityText(source, e))
+		}
+	})
+	return hashtags
+}
+
+func (m *Message) eachEntity(fn func(source string, e MessageEntity)) {
+	for _, e := range m.Entities {
+		fn(m.Text, e)
+	}
+	for _, e := range m.CaptionEntities {
+		fn(m.Caption, e)
+	}
+}
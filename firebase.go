@@ -3,6 +3,7 @@ package tgbot
 import (
 	"context"
 	"strconv"
+	"time"
 
 	"cloud.google.com/go/firestore"
 	"firebase.google.com/go/v4/db"
@@ -59,3 +60,104 @@ func (fb *Firebase[BOTDATA, USERDATA]) UpdateUser(user *User[USERDATA]) error {
 
 	return err
 }
+
+// StoredQuery is the persisted form of a query sent with
+// Session.SendNamedQuery. A Firestore TTL policy on expiresAt can be used to
+// purge expired documents; they are ignored on lookup either way.
+type StoredQuery struct {
+	ID        string            `firestore:"id"`
+	SessionID int64             `firestore:"sessionId"`
+	Answers   map[string]string `firestore:"answers"`
+	Handler   string            `firestore:"handler"`
+	ExpiresAt time.Time         `firestore:"expiresAt"`
+}
+
+func (fb *Firebase[BOTDATA, USERDATA]) GetQuery(id string) (*StoredQuery, error) {
+	iter := fb.Firestore.Collection("queries").Where("id", "==", id).Documents(fb.Context)
+
+	doc, err := iter.Next()
+	if err == iterator.Done {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var query *StoredQuery
+	err = doc.DataTo(&query)
+	return query, err
+}
+
+func (fb *Firebase[BOTDATA, USERDATA]) UpdateQuery(query *StoredQuery) error {
+	_, err := fb.Firestore.Collection("queries").Doc(query.ID).Set(fb.Context, query)
+
+	return err
+}
+
+func (fb *Firebase[BOTDATA, USERDATA]) DeleteQuery(id string) error {
+	_, err := fb.Firestore.Collection("queries").Doc(id).Delete(fb.Context)
+
+	return err
+}
+
+// StoredBroadcast is the persisted state of a BroadcastJob. Recipients with
+// an ID up to Cursor have been reserved and are skipped when it resumes.
+type StoredBroadcast struct {
+	ID              string         `firestore:"id"`
+	Text            string         `firestore:"text"`
+	ParseMode       ParseMode      `firestore:"parseMode"`
+	Media           InputMediaType `firestore:"media"`
+	FileID          string         `firestore:"fileId"`
+	FileURL         string         `firestore:"fileUrl"`
+	Segment         string         `firestore:"segment"`
+	Cursor          *int64         `firestore:"cursor"`
+	State           BroadcastState `firestore:"state"`
+	Sent            int            `firestore:"sent"`
+	Failed          int            `firestore:"failed"`
+	Blocked         int            `firestore:"blocked"`
+	Skipped         int            `firestore:"skipped"`
+	Reserved        int            `firestore:"reserved"`
+	ReportChatID    int64          `firestore:"reportChatId"`
+	ReportMessageID int            `firestore:"reportMessageId"`
+	CreatedAt       time.Time      `firestore:"createdAt"`
+}
+
+func (b *StoredBroadcast) file() InputFile {
+	if b.FileID != "" {
+		return FileFromID(b.FileID)
+	}
+	return FileFromURL(b.FileURL)
+}
+
+func (fb *Firebase[BOTDATA, USERDATA]) GetBroadcasts() ([]*StoredBroadcast, error) {
+	broadcasts := make([]*StoredBroadcast, 0)
+
+	iter := fb.Firestore.Collection("broadcasts").Documents(fb.Context)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var broadcast StoredBroadcast
+		doc.DataTo(&broadcast)
+		broadcasts = append(broadcasts, &broadcast)
+	}
+
+	return broadcasts, nil
+}
+
+func (fb *Firebase[BOTDATA, USERDATA]) UpdateBroadcast(broadcast *StoredBroadcast) error {
+	_, err := fb.Firestore.Collection("broadcasts").Doc(broadcast.ID).Set(fb.Context, broadcast)
+
+	return err
+}
+
+func (fb *Firebase[BOTDATA, USERDATA]) DeleteBroadcast(id string) error {
+	_, err := fb.Firestore.Collection("broadcasts").Doc(id).Delete(fb.Context)
+
+	return err
+}
@@ -0,0 +1,276 @@
+package tgbot
+
+import (
+	"strconv"
+	"strings"
+)
+
+var (
+	htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+	markdownLegacyEscaper = strings.NewReplacer("_", `\_`, "*", `\*`, "`", "\\`", "[", `\[`)
+
+	// Legacy Markdown can't escape inside a link URL, where ")" would end
+	// it, so it is percent-encoded instead.
+	markdownLegacyURLEscaper = strings.NewReplacer(")", "%29")
+
+	markdownV2Escaper = strings.NewReplacer(
+		`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+		"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+		"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+	)
+
+	// Inside code and pre entities only these need escaping in MarkdownV2,
+	// and inside the URL of a link only ")" and "\".
+	markdownV2CodeEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`")
+	markdownV2URLEscaper  = strings.NewReplacer(`\`, `\\`, ")", `\)`)
+)
+
+func EscapeHTML(s string) string {
+	return htmlEscaper.Replace(s)
+}
+
+// EscapeMarkdownLegacy escapes s for the legacy Markdown mode, which cannot
+// escape inside an entity, so s must be placed outside of one.
+func EscapeMarkdownLegacy(s string) string {
+	return markdownLegacyEscaper.Replace(s)
+}
+
+func EscapeMarkdownV2(s string) string {
+	return markdownV2Escaper.Replace(s)
+}
+
+// Escape escapes s so that it is shown verbatim in a message sent with mode.
+func Escape(mode ParseMode, s string) string {
+	switch mode {
+	case ParseModeHTML:
+		return EscapeHTML(s)
+	case ParseModeMarkdownLegacy:
+		return EscapeMarkdownLegacy(s)
+	case ParseModeMarkdownV2:
+		return EscapeMarkdownV2(s)
+	default:
+		return s
+	}
+}
+
+type textSpan struct {
+	text     string
+	entity   MessageEntityType
+	url      string
+	userID   int64
+	language string
+}
+
+// TextBuilder composes a message from plain and formatted spans. User input
+// passed to it is escaped when rendering, so it can never break the markup.
+// Legacy Markdown has no underline, strikethrough, spoiler or quote; those
+// spans render as plain text in that mode.
+type TextBuilder struct {
+	spans []textSpan
+}
+
+func NewTextBuilder() *TextBuilder {
+	return &TextBuilder{}
+}
+
+func (b *TextBuilder) add(span textSpan) *TextBuilder {
+	if span.text != "" {
+		b.spans = append(b.spans, span)
+	}
+	return b
+}
+
+func (b *TextBuilder) Text(s string) *TextBuilder {
+	return b.add(textSpan{text: s})
+}
+
+func (b *TextBuilder) Bold(s string) *TextBuilder {
+	return b.add(textSpan{text: s, entity: EntityBold})
+}
+
+func (b *TextBuilder) Italic(s string) *TextBuilder {
+	return b.add(textSpan{text: s, entity: EntityItalic})
+}
+
+func (b *TextBuilder) Underline(s string) *TextBuilder {
+	return b.add(textSpan{text: s, entity: EntityUnderline})
+}
+
+func (b *TextBuilder) Strikethrough(s string) *TextBuilder {
+	return b.add(textSpan{text: s, entity: EntityStrikethrough})
+}
+
+func (b *TextBuilder) Spoiler(s string) *TextBuilder {
+	return b.add(textSpan{text: s, entity: EntitySpoiler})
+}
+
+func (b *TextBuilder) Code(s string) *TextBuilder {
+	return b.add(textSpan{text: s, entity: EntityCode})
+}
+
+// Pre adds a preformatted block, highlighted as language when it is set.
+func (b *TextBuilder) Pre(s string, language string) *TextBuilder {
+	return b.add(textSpan{text: s, entity: EntityPre, language: language})
+}
+
+func (b *TextBuilder) Link(s string, url string) *TextBuilder {
+	return b.add(textSpan{text: s, entity: EntityTextLink, url: url})
+}
+
+// Mention links s to the user's profile, notifying them even if they have
+// no username.
+func (b *TextBuilder) Mention(s string, userID int64) *TextBuilder {
+	return b.add(textSpan{text: s, entity: EntityTextMention, userID: userID})
+}
+
+// Quote adds a block quote. It should start and end on a line of its own,
+// since in MarkdownV2 the rest of the line belongs to the quote.
+func (b *TextBuilder) Quote(s string) *TextBuilder {
+	return b.add(textSpan{text: s, entity: EntityBlockquote})
+}
+
+func (b *TextBuilder) String() string {
+	return b.Render(ParseModePlain)
+}
+
+// Render returns the text formatted for mode.
+func (b *TextBuilder) Render(mode ParseMode) string {
+	var sb strings.Builder
+	for idx, span := range b.spans {
+		switch mode {
+		case ParseModeHTML:
+			sb.WriteString(renderHTML(span))
+		case ParseModeMarkdownLegacy:
+			sb.WriteString(renderMarkdownLegacy(span))
+		case ParseModeMarkdownV2:
+			// Adjacent "_" and "__" markers would be read as one underline
+			// marker; an empty bold entity separates the two.
+			if idx > 0 && isUnderscoreEntity(b.spans[idx-1].entity) && isUnderscoreEntity(span.entity) {
+				sb.WriteString("**")
+			}
+			sb.WriteString(renderMarkdownV2(span))
+		default:
+			sb.WriteString(span.text)
+		}
+	}
+	return sb.String()
+}
+
+// Entities returns the plain text together with the entities describing
+// its formatting, for sending with MessageConfig.Entities.
+func (b *TextBuilder) Entities() (string, []MessageEntity) {
+	var sb strings.Builder
+	entities := make([]MessageEntity, 0)
+	offset := 0
+	for _, span := range b.spans {
+		length := utf16Len(span.text)
+		if span.entity != "" {
+			entity := MessageEntity{
+				Type:     span.entity,
+				Offset:   offset,
+				Length:   length,
+				URL:      span.url,
+				Language: span.language,
+			}
+			if span.entity == EntityTextMention {
+				entity.User = &MessageSender{ID: span.userID}
+			}
+			entities = append(entities, entity)
+		}
+		sb.WriteString(span.text)
+		offset += length
+	}
+	return sb.String(), entities
+}
+
+func isUnderscoreEntity(t MessageEntityType) bool {
+	return t == EntityItalic || t == EntityUnderline
+}
+
+func mentionURL(userID int64) string {
+	return "tg://user?id=" + strconv.FormatInt(userID, 10)
+}
+
+func renderHTML(span textSpan) string {
+	text := EscapeHTML(span.text)
+	switch span.entity {
+	case EntityBold:
+		return "<b>" + text + "</b>"
+	case EntityItalic:
+		return "<i>" + text + "</i>"
+	case EntityUnderline:
+		return "<u>" + text + "</u>"
+	case EntityStrikethrough:
+		return "<s>" + text + "</s>"
+	case EntitySpoiler:
+		return "<tg-spoiler>" + text + "</tg-spoiler>"
+	case EntityCode:
+		return "<code>" + text + "</code>"
+	case EntityPre:
+		if span.language != "" {
+			return `<pre><code class="language-` + EscapeHTML(span.language) + `">` + text + "</code></pre>"
+		}
+		return "<pre>" + text + "</pre>"
+	case EntityTextLink:
+		return `<a href="` + EscapeHTML(span.url) + `">` + text + "</a>"
+	case EntityTextMention:
+		return `<a href="` + mentionURL(span.userID) + `">` + text + "</a>"
+	case EntityBlockquote:
+		return "<blockquote>" + text + "</blockquote>"
+	default:
+		return text
+	}
+}
+
+func renderMarkdownLegacy(span textSpan) string {
+	// Markers inside an entity can't be escaped in legacy Markdown, so they
+	// are dropped instead.
+	strip := func(s string, marker string) string {
+		return strings.ReplaceAll(s, marker, "")
+	}
+	switch span.entity {
+	case EntityBold:
+		return "*" + strip(span.text, "*") + "*"
+	case EntityItalic:
+		return "_" + strip(span.text, "_") + "_"
+	case EntityCode:
+		return "`" + strip(span.text, "`") + "`"
+	case EntityPre:
+		return "```" + span.language + "\n" + strip(span.text, "```") + "\n```"
+	case EntityTextLink:
+		return "[" + strip(span.text, "]") + "](" + markdownLegacyURLEscaper.Replace(span.url) + ")"
+	case EntityTextMention:
+		return "[" + strip(span.text, "]") + "](" + mentionURL(span.userID) + ")"
+	default:
+		return EscapeMarkdownLegacy(span.text)
+	}
+}
+
+func renderMarkdownV2(span textSpan) string {
+	text := EscapeMarkdownV2(span.text)
+	switch span.entity {
+	case EntityBold:
+		return "*" + text + "*"
+	case EntityItalic:
+		return "_" + text + "_"
+	case EntityUnderline:
+		return "__" + text + "__"
+	case EntityStrikethrough:
+		return "~" + text + "~"
+	case EntitySpoiler:
+		return "||" + text + "||"
+	case EntityCode:
+		return "`" + markdownV2CodeEscaper.Replace(span.text) + "`"
+	case EntityPre:
+		return "```" + span.language + "\n" + markdownV2CodeEscaper.Replace(span.text) + "\n```"
+	case EntityTextLink:
+		return "[" + text + "](" + markdownV2URLEscaper.Replace(span.url) + ")"
+	case EntityTextMention:
+		return "[" + text + "](" + mentionURL(span.userID) + ")"
+	case EntityBlockquote:
+		return ">" + strings.ReplaceAll(text, "\n", "\n>")
+	default:
+		return text
+	}
+}
@@ -0,0 +1,156 @@
+package tgbot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		mode ParseMode
+		text string
+		want string
+	}{
+		{ParseModePlain, "a_b *c*", "a_b *c*"},
+		{ParseModeHTML, `<a href="x">&</a>`, "&lt;a href=&quot;x&quot;&gt;&amp;&lt;/a&gt;"},
+		{ParseModeMarkdownLegacy, "a_b *c* `d` [e]", "a\\_b \\*c\\* \\`d\\` \\[e]"},
+		{ParseModeMarkdownV2, "1.5 (a_b) -c!", `1\.5 \(a\_b\) \-c\!`},
+		{ParseModeMarkdownV2, `a\b`, `a\\b`},
+	}
+	for _, tt := range tests {
+		if got := Escape(tt.mode, tt.text); got != tt.want {
+			t.Errorf("Escape(%q, %q) = %q, want %q", tt.mode, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTextBuilderRender(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *TextBuilder
+		mode    ParseMode
+		want    string
+	}{
+		{
+			name:    "plain",
+			builder: NewTextBuilder().Text("a_b ").Bold("<c>"),
+			mode:    ParseModePlain,
+			want:    "a_b <c>",
+		},
+		{
+			name:    "html escapes text",
+			builder: NewTextBuilder().Text("a < b ").Bold("c & d"),
+			mode:    ParseModeHTML,
+			want:    "a &lt; b <b>c &amp; d</b>",
+		},
+		{
+			name:    "html pre with language",
+			builder: NewTextBuilder().Pre("x := 1", "go"),
+			mode:    ParseModeHTML,
+			want:    `<pre><code class="language-go">x := 1</code></pre>`,
+		},
+		{
+			name:    "html link and mention",
+			builder: NewTextBuilder().Link("site", `http://x.io/?a=1&b="2"`).Mention("me", 42),
+			mode:    ParseModeHTML,
+			want:    `<a href="http://x.io/?a=1&amp;b=&quot;2&quot;">site</a><a href="tg://user?id=42">me</a>`,
+		},
+		{
+			name:    "markdownv2 escapes text",
+			builder: NewTextBuilder().Text("1.5 ").Bold("a*b").Italic("c_d"),
+			mode:    ParseModeMarkdownV2,
+			want:    `1\.5 *a\*b*_c\_d_`,
+		},
+		{
+			name:    "markdownv2 separates adjacent underscore entities",
+			builder: NewTextBuilder().Italic("i").Underline("u"),
+			mode:    ParseModeMarkdownV2,
+			want:    "_i_**__u__",
+		},
+		{
+			name:    "markdownv2 code escapes only backticks and backslashes",
+			builder: NewTextBuilder().Code("a.b`c\\"),
+			mode:    ParseModeMarkdownV2,
+			want:    "`a.b\\`c\\\\`",
+		},
+		{
+			name:    "markdownv2 link URL",
+			builder: NewTextBuilder().Link("a)b", "http://x.io/a_(b)"),
+			mode:    ParseModeMarkdownV2,
+			want:    `[a\)b](http://x.io/a_(b\))`,
+		},
+		{
+			name:    "markdownv2 quote",
+			builder: NewTextBuilder().Quote("a\nb."),
+			mode:    ParseModeMarkdownV2,
+			want:    ">a\n>b\\.",
+		},
+		{
+			name:    "markdownv2 spoiler and strikethrough",
+			builder: NewTextBuilder().Spoiler("s").Strikethrough("t"),
+			mode:    ParseModeMarkdownV2,
+			want:    "||s||~t~",
+		},
+		{
+			name:    "legacy markdown drops markers inside entities",
+			builder: NewTextBuilder().Text("a_b ").Bold("x*y").Italic("i_j"),
+			mode:    ParseModeMarkdownLegacy,
+			want:    `a\_b *xy*_ij_`,
+		},
+		{
+			name:    "legacy markdown renders unsupported entities as text",
+			builder: NewTextBuilder().Underline("u_").Strikethrough("s").Spoiler("p"),
+			mode:    ParseModeMarkdownLegacy,
+			want:    `u\_sp`,
+		},
+		{
+			name:    "legacy markdown link URL",
+			builder: NewTextBuilder().Link("a]b", "http://x.io/a_(b)"),
+			mode:    ParseModeMarkdownLegacy,
+			want:    "[ab](http://x.io/a_(b%29)",
+		},
+		{
+			name:    "legacy markdown pre",
+			builder: NewTextBuilder().Pre("x := 1", "go"),
+			mode:    ParseModeMarkdownLegacy,
+			want:    "```go\nx := 1\n```",
+		},
+		{
+			name:    "empty spans are skipped",
+			builder: NewTextBuilder().Bold("").Text("a").Italic(""),
+			mode:    ParseModeMarkdownV2,
+			want:    "a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.builder.Render(tt.mode); got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTextBuilderEntities(t *testing.T) {
+	text, entities := NewTextBuilder().
+		Text("😀 ").
+		Bold("b").
+		Text(" ").
+		Link("link", "http://x.io").
+		Pre("code", "go").
+		Mention("me", 7).
+		Entities()
+
+	if want := "😀 b linkcodeme"; text != want {
+		t.Errorf("text = %q, want %q", text, want)
+	}
+	want := []MessageEntity{
+		{Type: EntityBold, Offset: 3, Length: 1},
+		{Type: EntityTextLink, Offset: 5, Length: 4, URL: "http://x.io"},
+		{Type: EntityPre, Offset: 9, Length: 4, Language: "go"},
+		{Type: EntityTextMention, Offset: 13, Length: 2, User: &MessageSender{ID: 7}},
+	}
+	if !reflect.DeepEqual(entities, want) {
+		t.Errorf("entities = %+v, want %+v", entities, want)
+	}
+}
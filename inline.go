@@ -0,0 +1,156 @@
+package tgbot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Telegram accepts at most 50 results per answerInlineQuery call.
+const maxInlineQueryResults = 50
+
+var ErrInvalidInlineResult = errors.New("invalid inline query result")
+
+type InlineQuery struct {
+	ID       string
+	From     *MessageSender
+	Query    string
+	Offset   string
+	ChatType string
+}
+
+type ChosenInlineResult struct {
+	ResultID        string
+	From            *MessageSender
+	InlineMessageID string
+	Query           string
+}
+
+type InlineQueryResultType string
+
+const (
+	InlineResultArticle  InlineQueryResultType = "article"
+	InlineResultPhoto    InlineQueryResultType = "photo"
+	InlineResultDocument InlineQueryResultType = "document"
+)
+
+// InlineQueryResult is a single entry in an inline query answer. Photo and
+// document results refer either to a URL or to a previously uploaded file ID.
+type InlineQueryResult struct {
+	Type         InlineQueryResultType
+	ID           string
+	Title        string
+	Description  string
+	ThumbnailURL string
+	ReplyMarkup  *InlineKeyboardMarkup
+
+	MessageText string
+	URL         string
+	ParseMode   ParseMode
+
+	PhotoURL    string
+	PhotoFileID string
+	PhotoWidth  int
+	PhotoHeight int
+
+	DocumentURL    string
+	DocumentFileID string
+	MimeType       string
+
+	Caption string
+}
+
+// Validate catches results Telegram would reject the whole answer for.
+func (r *InlineQueryResult) Validate() error {
+	if r.Type == InlineResultArticle && r.MessageText == "" {
+		return fmt.Errorf("%w: article %q has no message text", ErrInvalidInlineResult, r.ID)
+	}
+	if r.ReplyMarkup != nil {
+		return r.ReplyMarkup.Validate()
+	}
+	return nil
+}
+
+func NewInlineArticle(id string, title string, messageText string) *InlineQueryResult {
+	return &InlineQueryResult{
+		Type:        InlineResultArticle,
+		ID:          id,
+		Title:       title,
+		MessageText: messageText,
+	}
+}
+
+func NewInlinePhoto(id string, photoURL string, thumbnailURL string) *InlineQueryResult {
+	return &InlineQueryResult{
+		Type:         InlineResultPhoto,
+		ID:           id,
+		PhotoURL:     photoURL,
+		ThumbnailURL: thumbnailURL,
+	}
+}
+
+func NewInlineCachedPhoto(id string, fileID string) *InlineQueryResult {
+	return &InlineQueryResult{
+		Type:        InlineResultPhoto,
+		ID:          id,
+		PhotoFileID: fileID,
+	}
+}
+
+func NewInlineDocument(id string, title string, documentURL string, mimeType string) *InlineQueryResult {
+	return &InlineQueryResult{
+		Type:        InlineResultDocument,
+		ID:          id,
+		Title:       title,
+		DocumentURL: documentURL,
+		MimeType:    mimeType,
+	}
+}
+
+func NewInlineCachedDocument(id string, title string, fileID string) *InlineQueryResult {
+	return &InlineQueryResult{
+		Type:           InlineResultDocument,
+		ID:             id,
+		Title:          title,
+		DocumentFileID: fileID,
+	}
+}
+
+type InlineQueryResultsButton struct {
+	Text           string
+	StartParameter string
+}
+
+// InlineQueryAnswer is returned by inline query handlers. CacheTime is the
+// number of seconds Telegram may cache the results server-side; when
+// NextOffset is empty and more than 50 results are given, the answer is
+// paginated automatically using the query offset.
+type InlineQueryAnswer struct {
+	Results    []*InlineQueryResult
+	CacheTime  int
+	IsPersonal bool
+	NextOffset string
+	Button     *InlineQueryResultsButton
+}
+
+// PaginateInlineResults returns the page of results starting at offset,
+// along with the offset of the next page or an empty string on the last page.
+func PaginateInlineResults(results []*InlineQueryResult, offset string, pageSize int) ([]*InlineQueryResult, string) {
+	if pageSize <= 0 || pageSize > maxInlineQueryResults {
+		pageSize = maxInlineQueryResults
+	}
+
+	start, err := strconv.Atoi(offset)
+	if err != nil || start < 0 {
+		start = 0
+	}
+	if start >= len(results) {
+		return nil, ""
+	}
+
+	end := start + pageSize
+	if end >= len(results) {
+		return results[start:], ""
+	}
+	return results[start:end], strconv.Itoa(end)
+}
@@ -0,0 +1,103 @@
+package tgbot
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/go-telegram/bot/models"
+)
+
+const defaultUploadFilename = "file"
+
+// InputFile is a file to send: either an upload read from a reader or byte
+// slice, the file ID of a previous upload, or a URL Telegram fetches
+// itself. Byte slices can be sent any number of times, readers only once.
+type InputFile struct {
+	filename string
+	reader   io.Reader
+	data     []byte
+	fileID   string
+	url      string
+}
+
+func FileFromReader(r io.Reader, filename string) InputFile {
+	return InputFile{filename: filename, reader: r}
+}
+
+func FileFromBytes(data []byte, filename string) InputFile {
+	return InputFile{filename: filename, data: data}
+}
+
+// FileFromID refers to a file already stored on Telegram's servers, such as
+// the file ID returned by a previous send.
+func FileFromID(fileID string) InputFile {
+	return InputFile{fileID: fileID}
+}
+
+func FileFromURL(url string) InputFile {
+	return InputFile{url: url}
+}
+
+// IsUpload reports whether the file content is sent with the request.
+func (f InputFile) IsUpload() bool {
+	return f.fileID == "" && f.url == ""
+}
+
+func (f InputFile) uploadFilename() string {
+	if f.filename == "" {
+		return defaultUploadFilename
+	}
+	return f.filename
+}
+
+// rereadable reports whether the file can be sent again, which is not the
+// case for uploads from a reader.
+func (f InputFile) rereadable() bool {
+	return !f.IsUpload() || f.data != nil
+}
+
+// content returns a reader over the upload, fresh for byte slices.
+func (f InputFile) content() io.Reader {
+	if f.data != nil {
+		return bytes.NewReader(f.data)
+	}
+	return f.reader
+}
+
+func (f InputFile) toModels() models.InputFile {
+	switch {
+	case f.fileID != "":
+		return &models.InputFileString{Data: f.fileID}
+	case f.url != "":
+		return &models.InputFileString{Data: f.url}
+	default:
+		return &models.InputFileUpload{Filename: f.uploadFilename(), Data: f.content()}
+	}
+}
+
+// MediaFileID returns the file ID of the media attached to the message, or
+// an empty string when it carries none. For photos the largest size is used.
+func (m *Message) MediaFileID() string {
+	if m == nil {
+		return ""
+	}
+	switch {
+	case len(m.Photo) > 0:
+		return m.LargestPhoto().FileID
+	case m.Animation != nil:
+		return m.Animation.FileID
+	case m.Video != nil:
+		return m.Video.FileID
+	case m.VideoNote != nil:
+		return m.VideoNote.FileID
+	case m.Audio != nil:
+		return m.Audio.FileID
+	case m.Document != nil:
+		return m.Document.FileID
+	case m.Voice != nil:
+		return m.Voice.FileID
+	case m.Sticker != nil:
+		return m.Sticker.FileID
+	}
+	return ""
+}
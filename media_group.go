@@ -0,0 +1,97 @@
+package tgbot
+
+import (
+	"context"
+	"errors"
+)
+
+const (
+	minMediaGroupSize = 2
+	maxMediaGroupSize = 10
+)
+
+var (
+	ErrMediaGroupSize  = errors.New("media group must contain 2 to 10 items")
+	ErrMediaGroupMixed = errors.New("documents and audio can only be grouped with items of the same type")
+)
+
+type InputMediaType string
+
+const (
+	InputMediaPhoto    InputMediaType = "photo"
+	InputMediaVideo    InputMediaType = "video"
+	InputMediaDocument InputMediaType = "document"
+	InputMediaAudio    InputMediaType = "audio"
+)
+
+// InputMedia is one item of a media group. Each item carries its own
+// caption; Telegram shows the album caption only when a single item has one.
+type InputMedia struct {
+	Type      InputMediaType
+	File      InputFile
+	Caption   string
+	ParseMode ParseMode
+}
+
+func NewInputPhoto(file InputFile, caption string) InputMedia {
+	return InputMedia{Type: InputMediaPhoto, File: file, Caption: caption}
+}
+
+func NewInputVideo(file InputFile, caption string) InputMedia {
+	return InputMedia{Type: InputMediaVideo, File: file, Caption: caption}
+}
+
+func NewInputDocument(file InputFile, caption string) InputMedia {
+	return InputMedia{Type: InputMediaDocument, File: file, Caption: caption}
+}
+
+func NewInputAudio(file InputFile, caption string) InputMedia {
+	return InputMedia{Type: InputMediaAudio, File: file, Caption: caption}
+}
+
+func validateMediaGroup(media []InputMedia) error {
+	if len(media) < minMediaGroupSize || len(media) > maxMediaGroupSize {
+		return ErrMediaGroupSize
+	}
+	for _, item := range media {
+		switch item.Type {
+		case InputMediaDocument, InputMediaAudio:
+			if item.Type != media[0].Type {
+				return ErrMediaGroupMixed
+			}
+		default:
+			if media[0].Type == InputMediaDocument || media[0].Type == InputMediaAudio {
+				return ErrMediaGroupMixed
+			}
+		}
+	}
+	return nil
+}
+
+// SendMediaGroup sends 2 to 10 items as one album. Photos and videos can be
+// mixed freely, while documents and audio must be grouped with their own
+// kind. One message is returned per item.
+func (s *Session[BOTDATA, USERDATA]) SendMediaGroup(media ...InputMedia) ([]*Message, error) {
+	if err := validateMediaGroup(media); err != nil {
+		return nil, err
+	}
+	action := ChatActionUploadPhoto
+	switch media[0].Type {
+	case InputMediaDocument:
+		action = ChatActionUploadDocument
+	case InputMediaAudio:
+		action = ChatActionUploadVoice
+	}
+	for _, item := range media {
+		if item.File.IsUpload() {
+			defer s.StartChatAction(action)()
+			break
+		}
+	}
+	msgs, err := s.client.bot.SendMediaGroup(context.Background(), s.ID, media, 0)
+	s.stopChatAction()
+	if err != nil {
+		s.processError(err)
+	}
+	return msgs, err
+}
@@ -0,0 +1,301 @@
+package tgbot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	menuCallbackPrefix = "m"
+
+	menuOpPage  = "p"
+	menuOpPress = "b"
+	menuOpNoop  = "x"
+)
+
+type menuItemKind int
+
+const (
+	menuItemButton menuItemKind = iota
+	menuItemSubmenu
+	menuItemToggle
+	menuItemURL
+)
+
+type menuItem[BOTDATA any, USERDATA any] struct {
+	kind    menuItemKind
+	label   string
+	url     string
+	submenu *Menu[BOTDATA, USERDATA]
+	handler func(*Session[BOTDATA, USERDATA], *MenuContext[BOTDATA, USERDATA])
+	get     func(*Session[BOTDATA, USERDATA]) bool
+	set     func(*Session[BOTDATA, USERDATA], bool)
+}
+
+// Menu is an inline keyboard that is edited in place as the user navigates
+// it. Menus are addressed by ID in callback data, so IDs must be unique and
+// short enough to fit Telegram's 64-byte callback data limit. Menus
+// registered at startup keep working for messages sent before a restart.
+type Menu[BOTDATA any, USERDATA any] struct {
+	ID        string
+	Text      string
+	ParseMode ParseMode
+	Columns   int
+	PageSize  int
+	BackLabel string
+
+	parent *Menu[BOTDATA, USERDATA]
+	items  []menuItem[BOTDATA, USERDATA]
+}
+
+// MenuContext is passed to button handlers and refers to the message the
+// menu is displayed in. Unless the handler navigates or closes the menu,
+// the current page is re-rendered once the handler returns. Handlers may set
+// Answer to give the user feedback on the tap.
+type MenuContext[BOTDATA any, USERDATA any] struct {
+	MessageID int
+	Menu      *Menu[BOTDATA, USERDATA]
+	Page      int
+	Answer    *CallbackAnswer
+
+	session *Session[BOTDATA, USERDATA]
+	handled bool
+}
+
+func NewMenu[BOTDATA any, USERDATA any](id string, text string) *Menu[BOTDATA, USERDATA] {
+	return &Menu[BOTDATA, USERDATA]{
+		ID:        id,
+		Text:      text,
+		Columns:   1,
+		BackLabel: "« Back",
+	}
+}
+
+func (m *Menu[BOTDATA, USERDATA]) AddButton(label string, handler func(*Session[BOTDATA, USERDATA], *MenuContext[BOTDATA, USERDATA])) *Menu[BOTDATA, USERDATA] {
+	m.items = append(m.items, menuItem[BOTDATA, USERDATA]{kind: menuItemButton, label: label, handler: handler})
+	return m
+}
+
+func (m *Menu[BOTDATA, USERDATA]) AddSubmenu(label string, submenu *Menu[BOTDATA, USERDATA]) *Menu[BOTDATA, USERDATA] {
+	submenu.parent = m
+	m.items = append(m.items, menuItem[BOTDATA, USERDATA]{kind: menuItemSubmenu, label: label, submenu: submenu})
+	return m
+}
+
+// AddToggle adds a checkbox-style button whose state is read with get and
+// flipped with set on every tap.
+func (m *Menu[BOTDATA, USERDATA]) AddToggle(label string, get func(*Session[BOTDATA, USERDATA]) bool, set func(*Session[BOTDATA, USERDATA], bool)) *Menu[BOTDATA, USERDATA] {
+	m.items = append(m.items, menuItem[BOTDATA, USERDATA]{kind: menuItemToggle, label: label, get: get, set: set})
+	return m
+}
+
+func (m *Menu[BOTDATA, USERDATA]) AddURL(label string, url string) *Menu[BOTDATA, USERDATA] {
+	m.items = append(m.items, menuItem[BOTDATA, USERDATA]{kind: menuItemURL, label: label, url: url})
+	return m
+}
+
+func (m *Menu[BOTDATA, USERDATA]) pageCount() int {
+	if m.PageSize <= 0 || len(m.items) == 0 {
+		return 1
+	}
+	return (len(m.items) + m.PageSize - 1) / m.PageSize
+}
+
+func (m *Menu[BOTDATA, USERDATA]) callbackData(op string, page int, index int) string {
+	return strings.Join([]string{menuCallbackPrefix, m.ID, op, strconv.Itoa(page), strconv.Itoa(index)}, ":")
+}
+
+func (m *Menu[BOTDATA, USERDATA]) render(session *Session[BOTDATA, USERDATA], page int) *InlineKeyboardMarkup {
+	pages := m.pageCount()
+	if page < 0 || page >= pages {
+		page = 0
+	}
+
+	start, end := 0, len(m.items)
+	if m.PageSize > 0 {
+		start = page * m.PageSize
+		end = min(start+m.PageSize, len(m.items))
+	}
+
+	columns := max(m.Columns, 1)
+	rows := make([][]InlineKeyboardButton, 0)
+	row := make([]InlineKeyboardButton, 0, columns)
+	for idx := start; idx < end; idx++ {
+		item := m.items[idx]
+		btn := InlineKeyboardButton{Text: item.label}
+		switch item.kind {
+		case menuItemURL:
+			btn.URL = item.url
+		case menuItemSubmenu:
+			btn.CallbackData = item.submenu.callbackData(menuOpPage, 0, 0)
+		case menuItemToggle:
+			if item.get != nil && item.get(session) {
+				btn.Text = "✅ " + item.label
+			} else {
+				btn.Text = "⬜ " + item.label
+			}
+			btn.CallbackData = m.callbackData(menuOpPress, page, idx)
+		default:
+			btn.CallbackData = m.callbackData(menuOpPress, page, idx)
+		}
+
+		row = append(row, btn)
+		if len(row) == columns {
+			rows = append(rows, row)
+			row = make([]InlineKeyboardButton, 0, columns)
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+
+	if pages > 1 {
+		nav := make([]InlineKeyboardButton, 0, 3)
+		if page > 0 {
+			nav = append(nav, InlineKeyboardButton{Text: "‹", CallbackData: m.callbackData(menuOpPage, page-1, 0)})
+		}
+		nav = append(nav, InlineKeyboardButton{
+			Text:         fmt.Sprintf("%d/%d", page+1, pages),
+			CallbackData: m.callbackData(menuOpNoop, page, 0),
+		})
+		if page < pages-1 {
+			nav = append(nav, InlineKeyboardButton{Text: "›", CallbackData: m.callbackData(menuOpPage, page+1, 0)})
+		}
+		rows = append(rows, nav)
+	}
+
+	if m.parent != nil {
+		rows = append(rows, []InlineKeyboardButton{
+			{Text: m.BackLabel, CallbackData: m.parent.callbackData(menuOpPage, 0, 0)},
+		})
+	}
+
+	return &InlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
+// Navigate replaces the displayed menu with another registered menu.
+func (mc *MenuContext[BOTDATA, USERDATA]) Navigate(menu *Menu[BOTDATA, USERDATA]) error {
+	mc.handled = true
+	mc.session.client.registerMenu(menu)
+	return mc.session.showMenu(mc.MessageID, menu, 0)
+}
+
+func (mc *MenuContext[BOTDATA, USERDATA]) Refresh() error {
+	mc.handled = true
+	return mc.session.showMenu(mc.MessageID, mc.Menu, mc.Page)
+}
+
+// Close removes the menu keyboard, leaving text in its place.
+func (mc *MenuContext[BOTDATA, USERDATA]) Close(text string) error {
+	mc.handled = true
+	_, err := mc.session.EditTextWithConfig(mc.MessageID, text, MessageConfig{})
+	if errors.Is(err, ErrMessageNotModified) {
+		return nil
+	}
+	return err
+}
+
+func (s *Session[BOTDATA, USERDATA]) SendMenu(menu *Menu[BOTDATA, USERDATA]) (*Message, error) {
+	s.client.registerMenu(menu)
+	return s.SendTextWithConfig(menu.Text, MessageConfig{
+		ParseMode:   menu.ParseMode,
+		ReplyMarkup: menu.render(s, 0),
+	})
+}
+
+func (s *Session[BOTDATA, USERDATA]) showMenu(messageID int, menu *Menu[BOTDATA, USERDATA], page int) error {
+	_, err := s.EditTextWithConfig(messageID, menu.Text, MessageConfig{
+		ParseMode:   menu.ParseMode,
+		ReplyMarkup: menu.render(s, page),
+	})
+	if errors.Is(err, ErrMessageNotModified) {
+		return nil
+	}
+	return err
+}
+
+func (c *Client[BOTDATA, USERDATA]) registerMenu(menu *Menu[BOTDATA, USERDATA]) {
+	c.menuMu.Lock()
+	defer c.menuMu.Unlock()
+	c.registerMenuLocked(menu)
+}
+
+func (c *Client[BOTDATA, USERDATA]) registerMenuLocked(menu *Menu[BOTDATA, USERDATA]) {
+	if existing, ok := c.menus[menu.ID]; ok && existing == menu {
+		return
+	}
+	c.menus[menu.ID] = menu
+	for _, item := range menu.items {
+		if item.kind == menuItemSubmenu {
+			c.registerMenuLocked(item.submenu)
+		}
+	}
+}
+
+func (c *Client[BOTDATA, USERDATA]) getMenu(id string) *Menu[BOTDATA, USERDATA] {
+	c.menuMu.RLock()
+	defer c.menuMu.RUnlock()
+	return c.menus[id]
+}
+
+func (c *Client[BOTDATA, USERDATA]) handleMenuCallback(session *Session[BOTDATA, USERDATA], query *CallbackQuery) bool {
+	if query == nil || query.Message == nil {
+		return false
+	}
+
+	parts := strings.Split(query.Data, ":")
+	if len(parts) != 5 || parts[0] != menuCallbackPrefix {
+		return false
+	}
+
+	menu := c.getMenu(parts[1])
+	if menu == nil {
+		return false
+	}
+	page, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return false
+	}
+	index, err := strconv.Atoi(parts[4])
+	if err != nil {
+		return false
+	}
+
+	messageID := query.Message.MessageID
+	switch parts[2] {
+	case menuOpPage:
+		_ = session.answerCallbackQuery(query.ID, nil)
+		session.showMenu(messageID, menu, page)
+	case menuOpPress:
+		if index < 0 || index >= len(menu.items) {
+			_ = session.answerCallbackQuery(query.ID, nil)
+			return true
+		}
+		item := menu.items[index]
+		ctx := &MenuContext[BOTDATA, USERDATA]{
+			MessageID: messageID,
+			Menu:      menu,
+			Page:      page,
+			session:   session,
+		}
+		switch item.kind {
+		case menuItemToggle:
+			if item.get != nil && item.set != nil {
+				item.set(session, !item.get(session))
+			}
+		case menuItemButton:
+			if item.handler != nil {
+				item.handler(session, ctx)
+			}
+		}
+		_ = session.answerCallbackQuery(query.ID, ctx.Answer)
+		if !ctx.handled {
+			session.showMenu(messageID, menu, page)
+		}
+	default:
+		_ = session.answerCallbackQuery(query.ID, nil)
+	}
+	return true
+}
@@ -0,0 +1,158 @@
+package tgbot
+
+import "strconv"
+
+const (
+	queryDoneKey  = "d"
+	queryOtherKey = "o"
+
+	defaultQueryDoneLabel = "Done"
+)
+
+// QueryOption is a query button whose Label is shown to the user and whose
+// Value is passed to the answer handler.
+type QueryOption struct {
+	Label string
+	Value string
+}
+
+// QueryConfig describes a query sent with Session.SendQueryWithConfig.
+// Each entry of Rows is rendered as one keyboard row. With MultiSelect,
+// options toggle in place until the Done button is tapped. When OtherLabel
+// is set, an extra button lets the user type a free-text answer instead,
+// asking for it with OtherPrompt, or OtherLabel when that is empty, as Ask
+// does.
+type QueryConfig struct {
+	Rows        [][]QueryOption
+	MultiSelect bool
+	DoneLabel   string
+	OtherLabel  string
+	OtherPrompt string
+	ParseMode   ParseMode
+}
+
+// QueryAnswer holds the selected values in option order, or the typed text
+// when the user chose the "other" option.
+type QueryAnswer struct {
+	Values []string
+	Text   string
+	Other  bool
+}
+
+// Value returns the single answer of a query: the first selected value or
+// the free-text reply.
+func (a QueryAnswer) Value() string {
+	if a.Other {
+		return a.Text
+	}
+	if len(a.Values) == 0 {
+		return ""
+	}
+	return a.Values[0]
+}
+
+// QueryOptions creates options whose values equal their labels.
+func QueryOptions(labels ...string) []QueryOption {
+	options := make([]QueryOption, 0, len(labels))
+	for _, label := range labels {
+		options = append(options, QueryOption{Label: label, Value: label})
+	}
+	return options
+}
+
+// QueryRows lays options out in rows of the given number of columns.
+func QueryRows(options []QueryOption, columns int) [][]QueryOption {
+	columns = max(columns, 1)
+	rows := make([][]QueryOption, 0, (len(options)+columns-1)/columns)
+	for start := 0; start < len(options); start += columns {
+		rows = append(rows, options[start:min(start+columns, len(options))])
+	}
+	return rows
+}
+
+func newPendingQuery[BOTDATA any, USERDATA any](sessionID int64, options []string) pendingQuery[BOTDATA, USERDATA] {
+	layout := make([][]string, 0, len(options))
+	for idx := range options {
+		layout = append(layout, []string{strconv.Itoa(idx)})
+	}
+	answers := queryAnswers(options)
+	return pendingQuery[BOTDATA, USERDATA]{
+		sessionID: sessionID,
+		answers:   answers,
+		labels:    answers,
+		layout:    layout,
+	}
+}
+
+func newConfiguredQuery[BOTDATA any, USERDATA any](sessionID int64, config QueryConfig) pendingQuery[BOTDATA, USERDATA] {
+	item := pendingQuery[BOTDATA, USERDATA]{
+		sessionID:   sessionID,
+		answers:     make(map[string]string),
+		labels:      make(map[string]string),
+		layout:      make([][]string, 0, len(config.Rows)),
+		multiSelect: config.MultiSelect,
+		selected:    make(map[string]bool),
+		doneLabel:   config.DoneLabel,
+		otherLabel:  config.OtherLabel,
+		otherPrompt: config.OtherPrompt,
+	}
+	if item.doneLabel == "" {
+		item.doneLabel = defaultQueryDoneLabel
+	}
+
+	idx := 0
+	for _, row := range config.Rows {
+		keys := make([]string, 0, len(row))
+		for _, option := range row {
+			key := strconv.Itoa(idx)
+			idx++
+			item.answers[key] = option.Value
+			item.labels[key] = option.Label
+			keys = append(keys, key)
+		}
+		if len(keys) > 0 {
+			item.layout = append(item.layout, keys)
+		}
+	}
+	return item
+}
+
+// selectedValues returns the values of selected options in layout order.
+func (q *pendingQuery[BOTDATA, USERDATA]) selectedValues() []string {
+	values := make([]string, 0, len(q.selected))
+	for _, row := range q.layout {
+		for _, key := range row {
+			if q.selected[key] {
+				values = append(values, q.answers[key])
+			}
+		}
+	}
+	return values
+}
+
+func (q *pendingQuery[BOTDATA, USERDATA]) deliver(session *Session[BOTDATA, USERDATA], answer QueryAnswer) *CallbackAnswer {
+	if q.answerHandler != nil {
+		return q.answerHandler(session, answer)
+	}
+	if q.handler != nil {
+		return q.handler(session, answer.Value())
+	}
+	return nil
+}
+
+func (s *Session[BOTDATA, USERDATA]) SendQueryWithConfig(prompt string, config QueryConfig, handler func(*Session[BOTDATA, USERDATA], QueryAnswer) *CallbackAnswer) (*Message, error) {
+	if handler == nil {
+		return nil, nil
+	}
+
+	item := newConfiguredQuery[BOTDATA, USERDATA](s.ID, config)
+	item.answerHandler = handler
+	_, markup := s.client.queryStore.Create(item)
+	if markup == nil {
+		return nil, nil
+	}
+	return s.SendTextWithConfig(prompt, MessageConfig{
+		ParseMode:   config.ParseMode,
+		ReplyMarkup: markup,
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 type queryStore[BOTDATA any, USERDATA any] struct {
@@ -23,32 +24,64 @@ func newQueryStore[BOTDATA any, USERDATA any](maxPerSession int) *queryStore[BOT
 		maxPerSession:  maxPerSession,
 		bySession:      make(map[int64]*LRUMap[string, pendingQuery[BOTDATA, USERDATA]]),
 		queryToSession: make(map[string]int64),
+		// Seeding the sequence with the start time keeps query IDs unique
+		// across restarts, which persisted queries rely on.
+		querySeq: uint64(time.Now().UnixNano()),
 	}
 }
 
-func (s *queryStore[BOTDATA, USERDATA]) Create(
-	sessionID int64,
-	options []string,
-	handler func(*Session[BOTDATA, USERDATA], string),
-) *InlineKeyboardMarkup {
-	if len(options) == 0 || handler == nil {
-		return nil
+func queryAnswers(options []string) map[string]string {
+	answers := make(map[string]string, len(options))
+	for idx, option := range options {
+		answers[strconv.Itoa(idx)] = option
 	}
+	return answers
+}
 
-	s.mu.Lock()
-	queryID := strconv.FormatUint(s.querySeq+1, 10)
-	s.querySeq++
+// markup renders the keyboard of a pending query. Multi-select options are
+// prefixed with their selection state.
+func (q *pendingQuery[BOTDATA, USERDATA]) markup(queryID string) *InlineKeyboardMarkup {
+	callbackToken := func(key string) string {
+		return strings.Join([]string{"q", queryID, key}, ":")
+	}
 
-	answers := make(map[string]string, len(options))
-	keyboardRows := make([][]InlineKeyboardButton, 0, len(options))
-	for idx, option := range options {
-		key := strconv.Itoa(idx)
-		callbackToken := strings.Join([]string{"q", queryID, key}, ":")
+	keyboardRows := make([][]InlineKeyboardButton, 0, len(q.layout)+2)
+	for _, row := range q.layout {
+		buttons := make([]InlineKeyboardButton, 0, len(row))
+		for _, key := range row {
+			label := q.labels[key]
+			if q.multiSelect {
+				if q.selected[key] {
+					label = "✅ " + label
+				} else {
+					label = "⬜ " + label
+				}
+			}
+			buttons = append(buttons, InlineKeyboardButton{Text: label, CallbackData: callbackToken(key)})
+		}
+		keyboardRows = append(keyboardRows, buttons)
+	}
+	if q.otherLabel != "" {
+		keyboardRows = append(keyboardRows, []InlineKeyboardButton{
+			{Text: q.otherLabel, CallbackData: callbackToken(queryOtherKey)},
+		})
+	}
+	if q.multiSelect {
 		keyboardRows = append(keyboardRows, []InlineKeyboardButton{
-			{Text: option, CallbackData: callbackToken},
+			{Text: q.doneLabel, CallbackData: callbackToken(queryDoneKey)},
 		})
-		answers[key] = option
 	}
+	return &InlineKeyboardMarkup{InlineKeyboard: keyboardRows}
+}
+
+func (s *queryStore[BOTDATA, USERDATA]) Create(item pendingQuery[BOTDATA, USERDATA]) (string, *InlineKeyboardMarkup) {
+	if len(item.answers) == 0 || (item.handler == nil && item.answerHandler == nil) {
+		return "", nil
+	}
+
+	s.mu.Lock()
+	queryID := strconv.FormatUint(s.querySeq+1, 36)
+	s.querySeq++
 
 	if existingSessionID, ok := s.queryToSession[queryID]; ok {
 		if m := s.bySession[existingSessionID]; m != nil {
@@ -60,26 +93,60 @@ func (s *queryStore[BOTDATA, USERDATA]) Create(
 		delete(s.queryToSession, queryID)
 	}
 
-	item := pendingQuery[BOTDATA, USERDATA]{
-		sessionID: sessionID,
-		answers:   answers,
-		handler:   handler,
-	}
-
-	sessionMap := s.bySession[sessionID]
+	sessionMap := s.bySession[item.sessionID]
 	if sessionMap == nil {
 		sessionMap = NewLRUMap[string, pendingQuery[BOTDATA, USERDATA]](s.maxPerSession)
-		s.bySession[sessionID] = sessionMap
+		s.bySession[item.sessionID] = sessionMap
 	}
 
 	evicted, evictedQueryID, _ := sessionMap.Put(queryID, item)
-	s.queryToSession[queryID] = sessionID
+	s.queryToSession[queryID] = item.sessionID
 	if evicted {
 		delete(s.queryToSession, evictedQueryID)
 	}
 	s.mu.Unlock()
 
-	return &InlineKeyboardMarkup{InlineKeyboard: keyboardRows}
+	return queryID, item.markup(queryID)
+}
+
+// Toggle flips the selection of key in a multi-select query and returns the
+// updated query without consuming it.
+func (s *queryStore[BOTDATA, USERDATA]) Toggle(queryID string, key string) (pendingQuery[BOTDATA, USERDATA], bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var zero pendingQuery[BOTDATA, USERDATA]
+	sessionID, ok := s.queryToSession[queryID]
+	if !ok {
+		return zero, false
+	}
+	sessionMap := s.bySession[sessionID]
+	if sessionMap == nil {
+		return zero, false
+	}
+	item, ok := sessionMap.Get(queryID)
+	if !ok || !item.multiSelect {
+		return zero, false
+	}
+
+	item.selected[key] = !item.selected[key]
+	return item, true
+}
+
+func (s *queryStore[BOTDATA, USERDATA]) Get(queryID string) (pendingQuery[BOTDATA, USERDATA], bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var zero pendingQuery[BOTDATA, USERDATA]
+	sessionID, ok := s.queryToSession[queryID]
+	if !ok {
+		return zero, false
+	}
+	sessionMap := s.bySession[sessionID]
+	if sessionMap == nil {
+		return zero, false
+	}
+	return sessionMap.Get(queryID)
 }
 
 func (s *queryStore[BOTDATA, USERDATA]) Take(queryID string) (pendingQuery[BOTDATA, USERDATA], bool) {
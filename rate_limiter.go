@@ -0,0 +1,216 @@
+package tgbot
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultGlobalRate   = 30
+	defaultPerChatRate  = 1
+	defaultPerGroupRate = 20
+	defaultPerChatBurst = 1
+
+	// Buckets of the least recently used chats are dropped beyond this.
+	maxRateLimitedChats = 10000
+)
+
+// RateLimitConfig sets the outgoing message limits. Zero values use
+// Telegram's documented limits: 30 messages per second overall, one per
+// second in a chat and 20 per minute in a group. Bulk sends, such as
+// broadcasts, get at most Bulk of the global rate, two thirds by default,
+// so that replies to users aren't held up behind them.
+//
+// PerChatBurst lets a chat receive that many messages at once, such as a
+// long reply split into chunks, before being held to PerChat. Telegram
+// tolerates short bursts but may answer longer ones with flood errors, so
+// it defaults to 1.
+type RateLimitConfig struct {
+	Disabled     bool
+	Global       int // per second
+	PerChat      int // per second
+	PerChatBurst int
+	PerGroup     int // per minute
+	Bulk         int // per second
+}
+
+type tokenBucket struct {
+	tokens float64
+	burst  float64
+	rate   float64 // tokens per second
+	last   time.Time
+}
+
+func newTokenBucket(burst int, rate float64) *tokenBucket {
+	return &tokenBucket{
+		tokens: float64(burst),
+		burst:  float64(burst),
+		rate:   rate,
+		last:   time.Now(),
+	}
+}
+
+// reserve refills the bucket and takes a token, returning how long to wait
+// until it is actually available. Tokens may go negative, so callers are
+// served in the order they reserved.
+func (tb *tokenBucket) reserve(now time.Time) time.Duration {
+	tb.tokens = min(tb.burst, tb.tokens+now.Sub(tb.last).Seconds()*tb.rate)
+	tb.last = now
+	tb.tokens--
+	if tb.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-tb.tokens / tb.rate * float64(time.Second))
+}
+
+// release returns a reserved token that won't be used.
+func (tb *tokenBucket) release() {
+	tb.tokens = min(tb.burst, tb.tokens+1)
+}
+
+// hold refills the bucket and empties it so that the next token is only
+// available after d.
+func (tb *tokenBucket) hold(now time.Time, d time.Duration) {
+	tb.tokens = min(tb.burst, tb.tokens+now.Sub(tb.last).Seconds()*tb.rate)
+	tb.last = now
+	tb.tokens = min(tb.tokens, -d.Seconds()*tb.rate)
+}
+
+type chatBuckets struct {
+	chat  *tokenBucket
+	group *tokenBucket
+}
+
+// rateLimiter makes senders wait until a message fits within the global,
+// per-chat and per-group limits. Senders reserve their slot on arrival, so
+// each chat and each priority is served in order.
+type rateLimiter struct {
+	config RateLimitConfig
+	mu     sync.Mutex
+	global *tokenBucket
+	bulk   *tokenBucket
+	chats  *LRUMap[int64, *chatBuckets]
+}
+
+func newRateLimiter(config RateLimitConfig) *rateLimiter {
+	if config.Global <= 0 {
+		config.Global = defaultGlobalRate
+	}
+	if config.PerChat <= 0 {
+		config.PerChat = defaultPerChatRate
+	}
+	if config.PerChatBurst <= 0 {
+		config.PerChatBurst = defaultPerChatBurst
+	}
+	if config.PerGroup <= 0 {
+		config.PerGroup = defaultPerGroupRate
+	}
+	if config.Bulk <= 0 || config.Bulk > config.Global {
+		config.Bulk = max(1, config.Global*2/3)
+	}
+	return &rateLimiter{
+		config: config,
+		global: newTokenBucket(config.Global, float64(config.Global)),
+		bulk:   newTokenBucket(config.Bulk, float64(config.Bulk)),
+		chats:  NewLRUMap[int64, *chatBuckets](maxRateLimitedChats),
+	}
+}
+
+func (rl *rateLimiter) buckets(chatID int64) *chatBuckets {
+	if b, ok := rl.chats.Get(chatID); ok {
+		return b
+	}
+	b := &chatBuckets{chat: newTokenBucket(rl.config.PerChatBurst, float64(rl.config.PerChat))}
+	// Group and channel IDs are negative.
+	if chatID < 0 {
+		b.group = newTokenBucket(rl.config.PerGroup, float64(rl.config.PerGroup)/60)
+	}
+	rl.chats.Put(chatID, b)
+	return b
+}
+
+type bulkKey struct{}
+
+// bulkContext marks sends made with ctx as bulk traffic, which is limited to
+// the bulk share of the global rate.
+func bulkContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bulkKey{}, true)
+}
+
+func isBulk(ctx context.Context) bool {
+	bulk, _ := ctx.Value(bulkKey{}).(bool)
+	return bulk
+}
+
+// wait blocks until a message can be sent to chatID or ctx is done.
+func (rl *rateLimiter) wait(ctx context.Context, chatID int64) error {
+	if rl == nil || rl.config.Disabled {
+		return nil
+	}
+	// Bulk sends queue for their share first and only then compete for the
+	// global budget, leaving the rest of it to other sends.
+	if isBulk(ctx) {
+		if err := rl.take(ctx, rl.bulk); err != nil {
+			return err
+		}
+	}
+
+	// The chat's own limits are waited for before the global one, so that a
+	// backlog in one chat doesn't hold global tokens other chats could use.
+	rl.mu.Lock()
+	b := rl.buckets(chatID)
+	rl.mu.Unlock()
+	chat := []*tokenBucket{b.chat}
+	if b.group != nil {
+		chat = append(chat, b.group)
+	}
+	if err := rl.take(ctx, chat...); err != nil {
+		return err
+	}
+	return rl.take(ctx, rl.global)
+}
+
+// take reserves a token from every bucket and waits until all of them are
+// available. The tokens are released if ctx is done first.
+func (rl *rateLimiter) take(ctx context.Context, buckets ...*tokenBucket) error {
+	rl.mu.Lock()
+	now := time.Now()
+	var delay time.Duration
+	for _, tb := range buckets {
+		delay = max(delay, tb.reserve(now))
+	}
+	rl.mu.Unlock()
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		rl.mu.Lock()
+		for _, tb := range buckets {
+			tb.release()
+		}
+		rl.mu.Unlock()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// observe holds back further sends to chatID for as long as a flood error
+// asks, up to maxHold, so that other senders to the chat don't run into it
+// too. Longer waits are left to Telegram, which fails the sends at once
+// rather than blocking their callers.
+func (rl *rateLimiter) observe(chatID int64, err error, maxHold time.Duration) {
+	var flood *TooManyRequestsError
+	if rl == nil || rl.config.Disabled || !errors.As(err, &flood) {
+		return
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.buckets(chatID).chat.hold(time.Now(), min(flood.RetryAfter, maxHold))
+}
@@ -0,0 +1,97 @@
+package tgbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultRetryAttempts  = 3
+	defaultRetryBaseDelay = 500 * time.Millisecond
+	defaultRetryMaxDelay  = 30 * time.Second
+)
+
+// TooManyRequestsError is returned when Telegram rejects a call for flooding.
+// It matches ErrTooManyRequests with errors.Is.
+type TooManyRequestsError struct {
+	RetryAfter  time.Duration
+	Description string
+}
+
+func (e *TooManyRequestsError) Error() string {
+	return fmt.Sprintf("%s: retry after %s", e.Description, e.RetryAfter)
+}
+
+func (e *TooManyRequestsError) Is(target error) bool {
+	return target == ErrTooManyRequests
+}
+
+// RetryPolicy controls how calls failing with ErrTooManyRequests,
+// ErrServerError or ErrNetwork are retried. Zero values retry up to 3 times
+// in total with jittered exponential backoff starting at 500ms and capped
+// at 30s; flood errors wait for the RetryAfter Telegram asks for, and are
+// returned instead when it is longer than MaxDelay.
+// Uploads read from an io.Reader are never retried, since the reader has
+// been consumed.
+type RetryPolicy struct {
+	Disabled    bool
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaultRetryAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = defaultRetryBaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = defaultRetryMaxDelay
+	}
+	return p
+}
+
+func isRetryable(err error) bool {
+	return errors.Is(err, ErrTooManyRequests) || errors.Is(err, ErrServerError) || errors.Is(err, ErrNetwork)
+}
+
+// backoff returns the delay before the given retry, counted from 1.
+func (p RetryPolicy) backoff(retry int, err error) time.Duration {
+	var flood *TooManyRequestsError
+	if errors.As(err, &flood) {
+		return flood.RetryAfter + time.Duration(rand.Int63n(int64(p.BaseDelay)))
+	}
+	delay := min(p.BaseDelay<<(retry-1), p.MaxDelay)
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// do runs call until it succeeds, fails permanently or runs out of
+// attempts. Non-retryable calls are attempted once.
+func (p RetryPolicy) do(ctx context.Context, retryable bool, call func() error) error {
+	attempts := p.MaxAttempts
+	if p.Disabled || !retryable {
+		attempts = 1
+	}
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil || attempt >= attempts || !isRetryable(err) {
+			return err
+		}
+		var flood *TooManyRequestsError
+		if errors.As(err, &flood) && flood.RetryAfter > p.MaxDelay {
+			return err
+		}
+		timer := time.NewTimer(p.backoff(attempt, err))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
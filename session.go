@@ -3,11 +3,26 @@ package tgbot
 import (
 	"context"
 	"errors"
-	"os"
+	"io"
 	"strings"
+	"sync"
 )
 
+// MessageConfig formats the text either with ParseMode or with Entities,
+// as returned by TextBuilder.Entities. Text with entities is never split,
+// since the offsets would no longer match.
 type MessageConfig struct {
+	ReplyToMessageID int
+	ParseMode        ParseMode
+	Entities         []MessageEntity
+	PromptKey        string
+	ReplyMarkup      ReplyMarkup
+}
+
+// MediaConfig is MessageConfig for media sends, with the text carried in
+// Caption.
+type MediaConfig struct {
+	Caption          string
 	ReplyToMessageID int
 	ParseMode        ParseMode
 	PromptKey        string
@@ -19,6 +34,17 @@ type Session[BOTDATA, USERDATA any] struct {
 	User           *User[USERDATA]
 	CommandSession *CommandSession
 	client         *Client[BOTDATA, USERDATA]
+
+	replyMu      sync.Mutex
+	replyMatch   func(*Message) bool
+	replyHandler func(*Session[BOTDATA, USERDATA], *Message)
+
+	actionMu   sync.Mutex
+	actionStop func()
+
+	// blockedMu guards the blocked state of User, which sends on any
+	// goroutine may change.
+	blockedMu sync.Mutex
 }
 
 func newSession[BOTDATA any, USERDATA any](user *User[USERDATA], client *Client[BOTDATA, USERDATA]) *Session[BOTDATA, USERDATA] {
@@ -32,77 +58,326 @@ func newSession[BOTDATA any, USERDATA any](user *User[USERDATA], client *Client[
 	}
 }
 
-func (s *Session[BOTDATA, USERDATA]) SendText(text string) error {
+func (s *Session[BOTDATA, USERDATA]) SendText(text string) (*Message, error) {
 	return s.SendTextWithConfig(text, MessageConfig{})
 }
 
-func (s *Session[BOTDATA, USERDATA]) ReplyText(text string, replyToMessageID int) error {
+func (s *Session[BOTDATA, USERDATA]) ReplyText(text string, replyToMessageID int) (*Message, error) {
 	return s.SendTextWithConfig(text, MessageConfig{
 		ReplyToMessageID: replyToMessageID,
 	})
 }
 
-func (s *Session[BOTDATA, USERDATA]) SendTextWithConfig(text string, config MessageConfig) error {
+func (s *Session[BOTDATA, USERDATA]) SendTextWithConfig(text string, config MessageConfig) (*Message, error) {
+	return s.sendText(context.Background(), text, config)
+}
+
+// sendText is SendTextWithConfig with a context, which may mark the send as
+// bulk traffic for the rate limiter.
+func (s *Session[BOTDATA, USERDATA]) sendText(ctx context.Context, text string, config MessageConfig) (*Message, error) {
 	if promptText := s.client.Preference.Texts.Prompts[config.PromptKey]; promptText != "" {
 		text = strings.Join([]string{text, promptText}, "\n\n")
 	}
 
-	opts := &SendMessageOpts{
-		ReplyToMessageID: config.ReplyToMessageID,
-		ParseMode:        config.ParseMode,
-		ReplyMarkup:      config.ReplyMarkup,
+	// Text over the length limit is sent as several messages; only the first
+	// one replies and only the last one carries the markup.
+	chunks := []string{text}
+	if len(config.Entities) == 0 {
+		var err error
+		if chunks, err = splitMessage(text, config.ParseMode, maxMessageLength); err != nil {
+			return nil, err
+		}
 	}
-	err := s.client.bot.SendMessage(context.Background(), s.ID, text, opts)
-	if err != nil {
-		s.processError(err)
+	for idx, chunk := range chunks[:len(chunks)-1] {
+		opts := s.messageOpts(config)
+		opts.ReplyMarkup = nil
+		if idx > 0 {
+			opts.ReplyToMessageID = 0
+		}
+		if _, err := s.sent(s.client.bot.SendMessage(ctx, s.ID, chunk, opts)); err != nil {
+			return nil, err
+		}
 	}
-	return err
+	opts := s.messageOpts(config)
+	if len(chunks) > 1 {
+		opts.ReplyToMessageID = 0
+	}
+	return s.sent(s.client.bot.SendMessage(ctx, s.ID, chunks[len(chunks)-1], opts))
 }
 
-func (s *Session[BOTDATA, USERDATA]) SendQuery(prompt string, options []string, handler func(*Session[BOTDATA, USERDATA], string)) error {
+func (s *Session[BOTDATA, USERDATA]) SendQuery(prompt string, options []string, handler func(*Session[BOTDATA, USERDATA], string) *CallbackAnswer) (*Message, error) {
 	markup := s.client.createPendingQuery(s.ID, options, handler)
 	if markup == nil {
-		return nil
+		return nil, nil
+	}
+	return s.SendTextWithConfig(prompt, MessageConfig{
+		ReplyMarkup: markup,
+	})
+}
+
+// SendNamedQuery is like SendQuery but answers are dispatched to the handler
+// registered under handlerName, which allows the query to be persisted when
+// Config.PersistQueries is set.
+func (s *Session[BOTDATA, USERDATA]) SendNamedQuery(prompt string, options []string, handlerName string) (*Message, error) {
+	markup, err := s.client.createNamedQuery(s.ID, options, handlerName)
+	if err != nil || markup == nil {
+		return nil, err
 	}
 	return s.SendTextWithConfig(prompt, MessageConfig{
 		ReplyMarkup: markup,
 	})
 }
 
-func (s *Session[BOTDATA, USERDATA]) SendImage(file *os.File, name string) error {
-	err := s.client.bot.SendPhoto(context.Background(), s.User.ID, file, name)
+// SendImage sends a photo and returns the file ID of its largest size, which
+// can be passed to FileFromID to send the same photo again without
+// re-uploading it.
+func (s *Session[BOTDATA, USERDATA]) SendImage(file InputFile) (*Message, string, error) {
+	return s.SendImageWithConfig(file, MediaConfig{})
+}
+
+func (s *Session[BOTDATA, USERDATA]) SendImageWithConfig(file InputFile, config MediaConfig) (*Message, string, error) {
+	return s.sendImage(context.Background(), file, config)
+}
+
+func (s *Session[BOTDATA, USERDATA]) sendImage(ctx context.Context, file InputFile, config MediaConfig) (*Message, string, error) {
+	defer s.uploading(file, ChatActionUploadPhoto)()
+	return s.sentMedia(s.client.bot.SendPhoto(ctx, s.ID, file, s.mediaOpts(config)))
+}
+
+func (s *Session[BOTDATA, USERDATA]) SendVideo(file InputFile, meta *VideoMeta) (*Message, string, error) {
+	return s.SendVideoWithConfig(file, meta, MediaConfig{})
+}
+
+func (s *Session[BOTDATA, USERDATA]) SendVideoWithConfig(file InputFile, meta *VideoMeta, config MediaConfig) (*Message, string, error) {
+	return s.sendVideo(context.Background(), file, meta, config)
+}
+
+func (s *Session[BOTDATA, USERDATA]) sendVideo(ctx context.Context, file InputFile, meta *VideoMeta, config MediaConfig) (*Message, string, error) {
+	defer s.uploading(file, ChatActionUploadVideo)()
+	return s.sentMedia(s.client.bot.SendVideo(ctx, s.ID, file, meta, s.mediaOpts(config)))
+}
+
+func (s *Session[BOTDATA, USERDATA]) SendAudio(file InputFile) (*Message, string, error) {
+	return s.SendAudioWithConfig(file, MediaConfig{})
+}
+
+func (s *Session[BOTDATA, USERDATA]) SendAudioWithConfig(file InputFile, config MediaConfig) (*Message, string, error) {
+	return s.sendAudio(context.Background(), file, config)
+}
+
+func (s *Session[BOTDATA, USERDATA]) sendAudio(ctx context.Context, file InputFile, config MediaConfig) (*Message, string, error) {
+	defer s.uploading(file, ChatActionUploadVoice)()
+	return s.sentMedia(s.client.bot.SendAudio(ctx, s.ID, file, s.mediaOpts(config)))
+}
+
+func (s *Session[BOTDATA, USERDATA]) SendFile(file InputFile) (*Message, string, error) {
+	return s.SendFileWithConfig(file, MediaConfig{})
+}
+
+func (s *Session[BOTDATA, USERDATA]) SendFileWithConfig(file InputFile, config MediaConfig) (*Message, string, error) {
+	return s.sendFile(context.Background(), file, config)
+}
+
+func (s *Session[BOTDATA, USERDATA]) sendFile(ctx context.Context, file InputFile, config MediaConfig) (*Message, string, error) {
+	defer s.uploading(file, ChatActionUploadDocument)()
+	return s.sentMedia(s.client.bot.SendDocument(ctx, s.ID, file, s.mediaOpts(config)))
+}
+
+// mediaOpts converts config, appending the prompt text to the caption the
+// same way SendTextWithConfig does for text.
+func (s *Session[BOTDATA, USERDATA]) mediaOpts(config MediaConfig) *SendMediaOpts {
+	caption := config.Caption
+	if promptText := s.client.Preference.Texts.Prompts[config.PromptKey]; promptText != "" {
+		if caption == "" {
+			caption = promptText
+		} else {
+			caption = strings.Join([]string{caption, promptText}, "\n\n")
+		}
+	}
+	return &SendMediaOpts{
+		Caption:          caption,
+		ReplyToMessageID: config.ReplyToMessageID,
+		ParseMode:        config.ParseMode,
+		ReplyMarkup:      config.ReplyMarkup,
+	}
+}
+
+func (s *Session[BOTDATA, USERDATA]) SendVoice(file InputFile, config MediaConfig) (*Message, string, error) {
+	defer s.uploading(file, ChatActionUploadVoice)()
+	return s.sentMedia(s.client.bot.SendVoice(context.Background(), s.ID, file, s.mediaOpts(config)))
+}
+
+// SendVideoNote sends a round video message. Video notes have no caption, so
+// Caption, ParseMode and PromptKey of config are ignored.
+func (s *Session[BOTDATA, USERDATA]) SendVideoNote(file InputFile, config MediaConfig) (*Message, string, error) {
+	defer s.uploading(file, ChatActionUploadVideoNote)()
+	return s.sentMedia(s.client.bot.SendVideoNote(context.Background(), s.ID, file, s.mediaOpts(config)))
+}
+
+func (s *Session[BOTDATA, USERDATA]) SendAnimation(file InputFile, config MediaConfig) (*Message, string, error) {
+	defer s.uploading(file, ChatActionUploadVideo)()
+	return s.sentMedia(s.client.bot.SendAnimation(context.Background(), s.ID, file, s.mediaOpts(config)))
+}
+
+// SendSticker sends a sticker; like video notes, stickers have no caption.
+func (s *Session[BOTDATA, USERDATA]) SendSticker(file InputFile, config MediaConfig) (*Message, string, error) {
+	defer s.uploading(file, ChatActionChooseSticker)()
+	return s.sentMedia(s.client.bot.SendSticker(context.Background(), s.ID, file, s.mediaOpts(config)))
+}
+
+// Only ReplyToMessageID and ReplyMarkup of config apply to the sends below.
+
+func (s *Session[BOTDATA, USERDATA]) SendLocation(location Location, config MessageConfig) (*Message, error) {
+	return s.sent(s.client.bot.SendLocation(context.Background(), s.ID, location, s.messageOpts(config)))
+}
+
+func (s *Session[BOTDATA, USERDATA]) SendVenue(venue Venue, config MessageConfig) (*Message, error) {
+	return s.sent(s.client.bot.SendVenue(context.Background(), s.ID, venue, s.messageOpts(config)))
+}
+
+func (s *Session[BOTDATA, USERDATA]) SendContact(contact Contact, config MessageConfig) (*Message, error) {
+	return s.sent(s.client.bot.SendContact(context.Background(), s.ID, contact, s.messageOpts(config)))
+}
+
+// SendPoll sends a poll or quiz. The poll ID of the returned message
+// identifies the PollAnswer updates it receives.
+func (s *Session[BOTDATA, USERDATA]) SendPoll(poll PollConfig, config MessageConfig) (*Message, error) {
+	return s.sent(s.client.bot.SendPoll(context.Background(), s.ID, poll, s.messageOpts(config)))
+}
+
+// SendDice sends an animated emoji with a random value, which is available
+// in the Dice of the returned message. An empty emoji sends DiceDie.
+func (s *Session[BOTDATA, USERDATA]) SendDice(emoji string, config MessageConfig) (*Message, error) {
+	return s.sent(s.client.bot.SendDice(context.Background(), s.ID, emoji, s.messageOpts(config)))
+}
+
+func (s *Session[BOTDATA, USERDATA]) messageOpts(config MessageConfig) *SendMessageOpts {
+	return &SendMessageOpts{
+		ReplyToMessageID: config.ReplyToMessageID,
+		ParseMode:        config.ParseMode,
+		Entities:         config.Entities,
+		ReplyMarkup:      config.ReplyMarkup,
+	}
+}
+
+func (s *Session[BOTDATA, USERDATA]) sent(msg *Message, err error) (*Message, error) {
+	s.stopChatAction()
 	if err != nil {
 		s.processError(err)
 	}
-	return err
+	return msg, err
 }
 
-func (s *Session[BOTDATA, USERDATA]) SendVideo(file *os.File, name string, meta *VideoMeta) error {
-	err := s.client.bot.SendVideo(context.Background(), s.User.ID, file, name, meta)
+func (s *Session[BOTDATA, USERDATA]) sentMedia(msg *Message, err error) (*Message, string, error) {
+	s.stopChatAction()
 	if err != nil {
 		s.processError(err)
+		return nil, "", err
 	}
-	return err
+	return msg, msg.MediaFileID(), nil
+}
+
+func (s *Session[BOTDATA, USERDATA]) EditText(messageID int, text string) (*Message, error) {
+	return s.EditTextWithConfig(messageID, text, MessageConfig{})
 }
 
-func (s *Session[BOTDATA, USERDATA]) SendAudio(file *os.File, name string) error {
-	err := s.client.bot.SendAudio(context.Background(), s.User.ID, file, name)
+// EditTextWithConfig replaces the text of a previously sent message. Only
+// ParseMode and ReplyMarkup of config apply to edits.
+func (s *Session[BOTDATA, USERDATA]) EditTextWithConfig(messageID int, text string, config MessageConfig) (*Message, error) {
+	opts := &EditMessageOpts{
+		ParseMode:   config.ParseMode,
+		ReplyMarkup: config.ReplyMarkup,
+	}
+	msg, err := s.client.bot.EditMessageText(context.Background(), s.ID, messageID, text, opts)
 	if err != nil {
 		s.processError(err)
 	}
-	return err
+	return msg, err
+}
+
+func (s *Session[BOTDATA, USERDATA]) EditReplyMarkup(messageID int, markup ReplyMarkup) (*Message, error) {
+	msg, err := s.client.bot.EditMessageReplyMarkup(context.Background(), s.ID, messageID, markup)
+	if err != nil {
+		s.processError(err)
+	}
+	return msg, err
 }
 
-func (s *Session[BOTDATA, USERDATA]) SendFile(file *os.File, name string) error {
-	err := s.client.bot.SendDocument(context.Background(), s.User.ID, file, name)
+func (s *Session[BOTDATA, USERDATA]) DeleteMessage(messageID int) error {
+	err := s.client.bot.DeleteMessage(context.Background(), s.ID, messageID)
 	if err != nil {
 		s.processError(err)
 	}
 	return err
 }
 
-func (s *Session[BOTDATA, USERDATA]) answerCallbackQuery(callbackQueryID string) error {
-	err := s.client.bot.answerCallbackQuery(context.Background(), callbackQueryID)
+func (s *Session[BOTDATA, USERDATA]) EditInlineText(inlineMessageID string, text string, config MessageConfig) error {
+	opts := &EditMessageOpts{
+		ParseMode:   config.ParseMode,
+		ReplyMarkup: config.ReplyMarkup,
+	}
+	return s.client.bot.EditInlineMessageText(context.Background(), inlineMessageID, text, opts)
+}
+
+func (s *Session[BOTDATA, USERDATA]) EditInlineReplyMarkup(inlineMessageID string, markup ReplyMarkup) error {
+	return s.client.bot.EditInlineMessageReplyMarkup(context.Background(), inlineMessageID, markup)
+}
+
+// awaitReply routes the next non-command message of the session accepted by
+// match, or any message when match is nil, to handler instead of the regular
+// handlers. Commands cancel the wait.
+func (s *Session[BOTDATA, USERDATA]) awaitReply(match func(*Message) bool, handler func(*Session[BOTDATA, USERDATA], *Message)) {
+	s.replyMu.Lock()
+	defer s.replyMu.Unlock()
+	s.replyMatch = match
+	s.replyHandler = handler
+}
+
+func (s *Session[BOTDATA, USERDATA]) takeReplyHandler(message *Message) func(*Session[BOTDATA, USERDATA], *Message) {
+	s.replyMu.Lock()
+	defer s.replyMu.Unlock()
+	if s.replyHandler == nil || (s.replyMatch != nil && !s.replyMatch(message)) {
+		return nil
+	}
+	handler := s.replyHandler
+	s.replyMatch = nil
+	s.replyHandler = nil
+	return handler
+}
+
+// Ask sends prompt with a force-reply keyboard and passes the user's reply
+// to handler. In groups only direct replies to the prompt are accepted, so
+// other members' messages are processed as usual.
+func (s *Session[BOTDATA, USERDATA]) Ask(prompt string, placeholder string, handler func(*Session[BOTDATA, USERDATA], *Message)) (*Message, error) {
+	return s.AskWithConfig(prompt, placeholder, MessageConfig{}, handler)
+}
+
+// AskWithConfig is Ask with message options. When the prompt replies to a
+// message, the force-reply is shown only to that message's sender; a
+// selective force-reply without a reply or mention is shown to nobody.
+func (s *Session[BOTDATA, USERDATA]) AskWithConfig(prompt string, placeholder string, config MessageConfig, handler func(*Session[BOTDATA, USERDATA], *Message)) (*Message, error) {
+	config.ReplyMarkup = &ForceReply{InputFieldPlaceholder: placeholder, Selective: config.ReplyToMessageID != 0}
+	msg, err := s.SendTextWithConfig(prompt, config)
+	if err != nil {
+		return nil, err
+	}
+
+	var match func(*Message) bool
+	if !msg.Chat.IsPrivate() {
+		match = func(reply *Message) bool {
+			return reply.ReplyToMessageID == msg.MessageID
+		}
+	}
+	s.awaitReply(match, handler)
+	return msg, nil
+}
+
+func (s *Session[BOTDATA, USERDATA]) DownloadFile(fileID string, w io.Writer) error {
+	return s.client.bot.DownloadFile(context.Background(), fileID, w)
+}
+
+func (s *Session[BOTDATA, USERDATA]) answerCallbackQuery(callbackQueryID string, answer *CallbackAnswer) error {
+	err := s.client.bot.answerCallbackQuery(context.Background(), callbackQueryID, answer)
 	if err != nil {
 		s.processError(err)
 	}
@@ -111,7 +386,6 @@ func (s *Session[BOTDATA, USERDATA]) answerCallbackQuery(callbackQueryID string)
 
 func (s *Session[BOTDATA, USERDATA]) processError(err error) {
 	if errors.Is(err, ErrForbidden) || errors.Is(err, ErrChatNotFound) {
-		s.User.Blocked = true
-		s.client.Firebase.UpdateUser(s.User)
+		s.client.setBlocked(s, true)
 	}
 }
@@ -0,0 +1,440 @@
+package tgbot
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Telegram rejects messages longer than 4096 UTF-16 code units. Markup is
+// counted too, which keeps chunks below the limit after entity parsing.
+const maxMessageLength = 4096
+
+type markupKind int
+
+const (
+	markupText markupKind = iota
+	markupAtom
+	markupOpen
+	markupClose
+)
+
+type breakPriority int
+
+const (
+	breakNone breakPriority = iota
+	breakSpace
+	breakLine
+	breakParagraph
+)
+
+// markupPiece is the unit messages are split on. Text is broken into words
+// and lines, while tags, escapes and links are kept whole. reopen, when set,
+// replaces text when an open piece is repeated at the start of a chunk.
+type markupPiece struct {
+	text   string
+	kind   markupKind
+	name   string
+	close  string
+	reopen string
+	brk    breakPriority
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// splitMessage splits text into chunks of at most limit UTF-16 code units,
+// preferring paragraph, then line, then word boundaries. Formatting that is
+// open at a cut is closed at the end of the chunk and reopened at the start
+// of the next one. Links are split at their text and repeated. Markup that
+// can't fit in a chunk on its own, such as a longer URL, makes it fail with
+// ErrMessageTooLong.
+func splitMessage(text string, mode ParseMode, limit int) ([]string, error) {
+	if utf16Len(text) <= limit {
+		return []string{text}, nil
+	}
+
+	pieces := splitPieces(tokenizeMarkup(text, mode), limit/4)
+	chunks := make([]string, 0)
+	var stack []markupPiece
+	for i := 0; i < len(pieces); {
+		prefix := reopenMarkup(stack)
+		size := utf16Len(prefix)
+
+		current := append([]markupPiece(nil), stack...)
+		snapshots := make([][]markupPiece, 0)
+		sizes := make([]int, 0)
+		for j := i; j < len(pieces); j++ {
+			current = applyMarkup(current, pieces[j])
+			size += utf16Len(pieces[j].text)
+			if size+utf16Len(closeMarkup(current)) > limit && j > i {
+				break
+			}
+			snapshots = append(snapshots, append([]markupPiece(nil), current...))
+			sizes = append(sizes, size)
+		}
+
+		end := len(snapshots) - 1
+		if i+len(snapshots) < len(pieces) {
+			end = chooseBreak(pieces[i:i+len(snapshots)], sizes, limit)
+		}
+
+		// Closing tags right after the cut cost nothing, since they replace
+		// the ones that would be added, and avoid empty entities.
+		stack = snapshots[end]
+		for i+end+1 < len(pieces) && pieces[i+end+1].kind == markupClose {
+			end++
+			stack = applyMarkup(stack, pieces[i+end])
+		}
+
+		var b strings.Builder
+		b.WriteString(prefix)
+		for _, p := range pieces[i : i+end+1] {
+			b.WriteString(p.text)
+		}
+		b.WriteString(closeMarkup(stack))
+		chunk := strings.TrimSpace(b.String())
+		if utf16Len(chunk) > limit {
+			return nil, ErrMessageTooLong
+		}
+		if chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		i += end + 1
+	}
+	if len(chunks) == 0 {
+		return []string{text}, nil
+	}
+	return chunks, nil
+}
+
+// chooseBreak returns the index of the piece to end the chunk with: the last
+// break of the highest priority that still fills at least half the chunk,
+// or the last piece that fits that doesn't open formatting.
+func chooseBreak(pieces []markupPiece, sizes []int, limit int) int {
+	for brk := breakParagraph; brk > breakNone; brk-- {
+		for j := len(pieces) - 1; j >= 0 && sizes[j] >= limit/2; j-- {
+			if pieces[j].brk == brk {
+				return j
+			}
+		}
+	}
+	j := len(pieces) - 1
+	for j > 0 && pieces[j].kind == markupOpen {
+		j--
+	}
+	return j
+}
+
+func applyMarkup(stack []markupPiece, p markupPiece) []markupPiece {
+	switch p.kind {
+	case markupOpen:
+		return append(stack, p)
+	case markupClose:
+		for i := len(stack) - 1; i >= 0; i-- {
+			if stack[i].name == p.name {
+				return append(stack[:i:i], stack[i+1:]...)
+			}
+		}
+	}
+	return stack
+}
+
+func reopenMarkup(stack []markupPiece) string {
+	var b strings.Builder
+	for _, p := range stack {
+		if p.reopen != "" {
+			b.WriteString(p.reopen)
+		} else {
+			b.WriteString(p.text)
+		}
+	}
+	return b.String()
+}
+
+func closeMarkup(stack []markupPiece) string {
+	var b strings.Builder
+	for i := len(stack) - 1; i >= 0; i-- {
+		b.WriteString(stack[i].close)
+	}
+	return b.String()
+}
+
+// splitPieces breaks text pieces after every space and newline, and splits
+// words longer than maxWord.
+func splitPieces(tokens []markupPiece, maxWord int) []markupPiece {
+	pieces := make([]markupPiece, 0, len(tokens))
+	for _, t := range tokens {
+		if t.kind != markupText {
+			pieces = append(pieces, t)
+			continue
+		}
+		start, prev := 0, rune(0)
+		for i, r := range t.text {
+			end := i + utf8.RuneLen(r)
+			word := t.text[start:end]
+			switch {
+			case r == '\n' && prev == '\n':
+				pieces = append(pieces, markupPiece{text: word, brk: breakParagraph})
+				start = end
+			case r == '\n':
+				pieces = append(pieces, markupPiece{text: word, brk: breakLine})
+				start = end
+			case r == ' ':
+				pieces = append(pieces, markupPiece{text: word, brk: breakSpace})
+				start = end
+			case utf16Len(word) >= maxWord:
+				pieces = append(pieces, markupPiece{text: word})
+				start = end
+			}
+			prev = r
+		}
+		if start < len(t.text) {
+			pieces = append(pieces, markupPiece{text: t.text[start:]})
+		}
+	}
+	return pieces
+}
+
+func tokenizeMarkup(text string, mode ParseMode) []markupPiece {
+	switch mode {
+	case ParseModeHTML:
+		return tokenizeHTML(text)
+	case ParseModeMarkdownLegacy:
+		return tokenizeMarkdownLegacy(text)
+	case ParseModeMarkdownV2:
+		return tokenizeMarkdownV2(text)
+	default:
+		return []markupPiece{{text: text}}
+	}
+}
+
+type markupTokenizer struct {
+	tokens []markupPiece
+	text   strings.Builder
+}
+
+func (t *markupTokenizer) flush() {
+	if t.text.Len() > 0 {
+		t.tokens = append(t.tokens, markupPiece{text: t.text.String()})
+		t.text.Reset()
+	}
+}
+
+func (t *markupTokenizer) add(p markupPiece) {
+	t.flush()
+	t.tokens = append(t.tokens, p)
+}
+
+// A "<" only starts a tag when it is followed by a tag name and closed
+// before the next "<"; anything else is split as plain text. Matching stops
+// at the next "<", so scanning stays linear.
+var htmlTagPattern = regexp.MustCompile(`^</?[a-zA-Z][\w-]*(?:\s[^<>]*)?>`)
+
+func tokenizeHTML(text string) []markupPiece {
+	var t markupTokenizer
+	for i := 0; i < len(text); {
+		switch text[i] {
+		case '<':
+			tag := htmlTagPattern.FindString(text[i:])
+			if tag == "" {
+				t.text.WriteByte(text[i])
+				i++
+				continue
+			}
+			if strings.HasPrefix(tag, "</") {
+				t.add(markupPiece{text: tag, kind: markupClose, name: strings.TrimSpace(tag[2 : len(tag)-1])})
+			} else {
+				name := strings.FieldsFunc(tag[1:len(tag)-1], func(r rune) bool { return unicode.IsSpace(r) || r == '/' })
+				if len(name) > 0 {
+					t.add(markupPiece{text: tag, kind: markupOpen, name: name[0], close: "</" + name[0] + ">"})
+				} else {
+					t.add(markupPiece{text: tag, kind: markupAtom})
+				}
+			}
+			i += len(tag)
+		case '&':
+			n := strings.IndexByte(text[i:], ';')
+			if n > 0 && n <= 10 {
+				t.add(markupPiece{text: text[i : i+n+1], kind: markupAtom})
+				i += n + 1
+				continue
+			}
+			t.text.WriteByte(text[i])
+			i++
+		default:
+			t.text.WriteByte(text[i])
+			i++
+		}
+	}
+	t.flush()
+	return t.tokens
+}
+
+// tokenizeMarkdownLegacy handles the legacy Markdown mode, where entities
+// can't be nested and only code and pre turn formatting off.
+func tokenizeMarkdownLegacy(text string) []markupPiece {
+	var t markupTokenizer
+	open := ""
+	toggle := func(marker string) {
+		if open == marker {
+			t.add(markupPiece{text: marker, kind: markupClose, name: marker})
+			open = ""
+		} else {
+			t.add(markupPiece{text: marker, kind: markupOpen, name: marker, close: marker})
+			open = marker
+		}
+	}
+	for i := 0; i < len(text); {
+		rest := text[i:]
+		switch {
+		case strings.HasPrefix(rest, "```") && open == "":
+			fence := codeFence(rest)
+			t.add(fence)
+			open = "```"
+			i += len(fence.text)
+		case strings.HasPrefix(rest, "```") && open == "```":
+			toggle("```")
+			i += 3
+		case rest[0] == '`' && (open == "" || open == "`"):
+			toggle("`")
+			i++
+		case (rest[0] == '*' || rest[0] == '_') && (open == "" || open == rest[:1]):
+			toggle(rest[:1])
+			i++
+		case rest[0] == '\\' && open == "" && len(rest) > 1:
+			t.add(markupPiece{text: rest[:2], kind: markupAtom})
+			i += 2
+		case rest[0] == '[' && open == "":
+			if n := markdownLinkLength(rest); n > 0 {
+				linkOpen, linkClose := markdownLink(rest[:n])
+				t.add(linkOpen)
+				t.text.WriteString(rest[1 : n-len(linkClose.text)])
+				t.add(linkClose)
+				i += n
+				continue
+			}
+			t.text.WriteByte(rest[0])
+			i++
+		default:
+			t.text.WriteByte(rest[0])
+			i++
+		}
+	}
+	t.flush()
+	return t.tokens
+}
+
+// codeFence returns the piece opening a code block at the start of s. It
+// includes the language line, which has to be repeated when the block is
+// reopened in the next chunk, or Telegram would take the first word of the
+// code for the language.
+func codeFence(s string) markupPiece {
+	text, language := "```", ""
+	if n := strings.IndexAny(s[3:], " \t\n`"); n >= 0 && s[3+n] == '\n' {
+		text, language = s[:3+n+1], s[3:3+n]
+	}
+	return markupPiece{text: text, kind: markupOpen, name: "```", close: "```", reopen: "```" + language + "\n"}
+}
+
+// markdownLink returns the pieces around the text of the link s, so that a
+// long link is split at its text and the URL repeated in every chunk.
+func markdownLink(s string) (markupPiece, markupPiece) {
+	target := s[strings.Index(s, "]("):]
+	return markupPiece{text: "[", kind: markupOpen, name: "[", close: target},
+		markupPiece{text: target, kind: markupClose, name: "["}
+}
+
+// markdownLinkLength returns the length of the [text](url) link at the
+// start of s, or 0 if there is none.
+func markdownLinkLength(s string) int {
+	mid := strings.Index(s, "](")
+	if mid < 0 || strings.ContainsRune(s[:mid], '\n') {
+		return 0
+	}
+	end := strings.IndexByte(s[mid:], ')')
+	if end < 0 {
+		return 0
+	}
+	return mid + end + 1
+}
+
+// markdownV2Markers are matched in order, so that longer markers win.
+var markdownV2Markers = []string{"```", "`", "||", "__", "_", "*", "~"}
+
+// tokenizeMarkdownV2 handles MarkdownV2, where entities nest and every
+// special character outside of them is escaped with a backslash.
+func tokenizeMarkdownV2(text string) []markupPiece {
+	var t markupTokenizer
+	open := make(map[string]bool)
+	code := ""
+	for i := 0; i < len(text); {
+		rest := text[i:]
+		if rest[0] == '\\' && len(rest) > 1 {
+			_, n := utf8.DecodeRuneInString(rest[1:])
+			t.add(markupPiece{text: rest[:1+n], kind: markupAtom})
+			i += 1 + n
+			continue
+		}
+		if code != "" {
+			if strings.HasPrefix(rest, code) {
+				t.add(markupPiece{text: code, kind: markupClose, name: code})
+				i += len(code)
+				code = ""
+				continue
+			}
+			t.text.WriteByte(rest[0])
+			i++
+			continue
+		}
+		if rest[0] == '[' {
+			if n := markdownLinkLength(rest); n > 0 {
+				linkOpen, linkClose := markdownLink(rest[:n])
+				t.add(linkOpen)
+				t.tokens = append(t.tokens, tokenizeMarkdownV2(rest[1:n-len(linkClose.text)])...)
+				t.add(linkClose)
+				i += n
+				continue
+			}
+		}
+		matched := ""
+		for _, marker := range markdownV2Markers {
+			if strings.HasPrefix(rest, marker) {
+				matched = marker
+				break
+			}
+		}
+		switch {
+		case matched == "":
+			t.text.WriteByte(rest[0])
+		case matched == "```":
+			fence := codeFence(rest)
+			t.add(fence)
+			code = matched
+			i += len(fence.text)
+			continue
+		case matched == "`":
+			t.add(markupPiece{text: matched, kind: markupOpen, name: matched, close: matched})
+			code = matched
+		case open[matched]:
+			t.add(markupPiece{text: matched, kind: markupClose, name: matched})
+			delete(open, matched)
+		default:
+			t.add(markupPiece{text: matched, kind: markupOpen, name: matched, close: matched})
+			open[matched] = true
+		}
+		i += max(len(matched), 1)
+	}
+	t.flush()
+	return t.tokens
+}
@@ -0,0 +1,196 @@
+package tgbot
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		mode  ParseMode
+		limit int
+		want  []string
+	}{
+		{
+			name:  "fits",
+			text:  "*short*",
+			mode:  ParseModeMarkdownV2,
+			limit: 20,
+			want:  []string{"*short*"},
+		},
+		{
+			name:  "paragraph break",
+			text:  "one two three\n\nfour five six seven",
+			mode:  ParseModePlain,
+			limit: 20,
+			want:  []string{"one two three", "four five six seven"},
+		},
+		{
+			name:  "line break over space",
+			text:  "one two\nthree four five six",
+			mode:  ParseModePlain,
+			limit: 16,
+			want:  []string{"one two", "three four five", "six"},
+		},
+		{
+			name:  "long word",
+			text:  strings.Repeat("x", 30),
+			mode:  ParseModePlain,
+			limit: 20,
+			want:  []string{strings.Repeat("x", 20), strings.Repeat("x", 10)},
+		},
+		{
+			name:  "html tag reopened",
+			text:  "<b>one two three four five six</b>",
+			mode:  ParseModeHTML,
+			limit: 24,
+			want:  []string{"<b>one two three </b>", "<b>four five six</b>"},
+		},
+		{
+			name:  "html nested tags reopened",
+			text:  "<b><i>one two three four</i> five</b>",
+			mode:  ParseModeHTML,
+			limit: 30,
+			want:  []string{"<b><i>one two three </i></b>", "<b><i>four</i> five</b>"},
+		},
+		{
+			name:  "html link reopened",
+			text:  `<a href="http://x.io">one two three four five six</a>`,
+			mode:  ParseModeHTML,
+			limit: 40,
+			want:  []string{`<a href="http://x.io">one two three </a>`, `<a href="http://x.io">four five six</a>`},
+		},
+		{
+			name:  "html entities kept whole",
+			text:  "a &amp;&amp;&amp;&amp;&amp; b c d e f",
+			mode:  ParseModeHTML,
+			limit: 26,
+			want:  []string{"a &amp;&amp;&amp;&amp;", "&amp; b c d e f"},
+		},
+		{
+			name:  "html stray less-than is text",
+			text:  "1 < 2 and 3 < 4 and 5 < 6",
+			mode:  ParseModeHTML,
+			limit: 16,
+			want:  []string{"1 < 2 and 3 < 4", "and 5 < 6"},
+		},
+		{
+			name:  "markdownv2 code block reopened with language",
+			text:  "```go\nfmt.Println(1)\nfmt.Println(2)\n```",
+			mode:  ParseModeMarkdownV2,
+			limit: 30,
+			want:  []string{"```go\nfmt.Println(1)\n```", "```go\nfmt.Println(2)\n```"},
+		},
+		{
+			name:  "markdownv2 nested markers reopened",
+			text:  "__*one two* three four five__",
+			mode:  ParseModeMarkdownV2,
+			limit: 20,
+			want:  []string{"__*one two* three __", "__four five__"},
+		},
+		{
+			name:  "markdownv2 escapes kept whole",
+			text:  `one\. two\. three\. four\.`,
+			mode:  ParseModeMarkdownV2,
+			limit: 16,
+			want:  []string{`one\. two\.`, `three\. four\.`},
+		},
+		{
+			name:  "markdownv2 link split at its text",
+			text:  "[one two three four five](http://x.io)",
+			mode:  ParseModeMarkdownV2,
+			limit: 30,
+			want:  []string{"[one two three ](http://x.io)", "[four five](http://x.io)"},
+		},
+		{
+			name:  "legacy markdown entity reopened",
+			text:  "*one two three four five six*",
+			mode:  ParseModeMarkdownLegacy,
+			limit: 16,
+			want:  []string{"*one two three *", "*four five six*"},
+		},
+		{
+			name:  "legacy markdown markers inside code are text",
+			text:  "`a*b c*d e*f g*h`",
+			mode:  ParseModeMarkdownLegacy,
+			limit: 12,
+			want:  []string{"`a*b c*d `", "`e*f g*h`"},
+		},
+		{
+			name:  "legacy markdown link split at its text",
+			text:  "[one two three four](http://x.io)",
+			mode:  ParseModeMarkdownLegacy,
+			limit: 26,
+			want:  []string{"[one two ](http://x.io)", "[three four](http://x.io)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitMessage(tt.text, tt.mode, tt.limit)
+			if err != nil {
+				t.Fatalf("splitMessage() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitMessage() = %q, want %q", got, tt.want)
+			}
+			for _, chunk := range got {
+				if n := utf16Len(chunk); n > tt.limit {
+					t.Errorf("chunk %q is %d long, over the limit of %d", chunk, n, tt.limit)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitMessageTooLong(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		mode ParseMode
+	}{
+		{
+			name: "html tag",
+			text: `<a href="http://` + strings.Repeat("x", 50) + `">one</a> two`,
+			mode: ParseModeHTML,
+		},
+		{
+			name: "markdownv2 link URL",
+			text: "[one](http://" + strings.Repeat("x", 50) + ") two",
+			mode: ParseModeMarkdownV2,
+		},
+		{
+			name: "legacy markdown link URL",
+			text: "[one](http://" + strings.Repeat("x", 50) + ") two",
+			mode: ParseModeMarkdownLegacy,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := splitMessage(tt.text, tt.mode, 40); !errors.Is(err, ErrMessageTooLong) {
+				t.Errorf("splitMessage() error = %v, want ErrMessageTooLong", err)
+			}
+		})
+	}
+}
+
+func TestUTF16Len(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"héllo", 5},
+		{"😀", 2},
+		{"a😀b", 4},
+	}
+	for _, tt := range tests {
+		if got := utf16Len(tt.text); got != tt.want {
+			t.Errorf("utf16Len(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package tgbot
 
+import "time"
+
 type TgBot[BOTDATA any, USERDATA any] struct {
 	Client *Client[BOTDATA, USERDATA]
 }
@@ -8,6 +10,28 @@ type Config struct {
 	TelegramBotToken    string
 	FirebaseCredential  []byte
 	FirebaseDatabaseURL string
+
+	// PersistQueries stores queries sent with Session.SendNamedQuery in
+	// Firestore so that their buttons keep working across restarts for
+	// QueryTTL (24 hours by default).
+	PersistQueries bool
+	QueryTTL       time.Duration
+
+	// ExpiredQueryText is shown to users tapping a button whose query is no
+	// longer known.
+	ExpiredQueryText string
+
+	// CallbackSecret signs callback data created with EncodeCallbackData.
+	// It defaults to a key derived from TelegramBotToken.
+	CallbackSecret []byte
+
+	// RateLimit throttles every outgoing message so that broadcasts and busy
+	// groups stay within Telegram's flood limits.
+	RateLimit RateLimitConfig
+
+	// Retry sets how calls failing with flood, server or network errors are
+	// retried.
+	Retry RetryPolicy
 }
 
 func NewBot[BOTDATA any, USERDATA any](config Config, delegate ClientDelegate[BOTDATA, USERDATA]) (*TgBot[BOTDATA, USERDATA], error) {
@@ -28,6 +52,144 @@ func (tgbot *TgBot[BOTDATA, USERDATA]) RegisterCommandHandler(cmd string, handle
 	tgbot.Client.registerCommandHandler(cmd, handler)
 }
 
+func (tgbot *TgBot[BOTDATA, USERDATA]) RegisterPhotoHandler(handler func(*Session[BOTDATA, USERDATA], []PhotoSize, *Message)) {
+	tgbot.Client.registerPhotoHandler(handler)
+}
+
+func (tgbot *TgBot[BOTDATA, USERDATA]) RegisterDocumentHandler(handler func(*Session[BOTDATA, USERDATA], *Document, *Message)) {
+	tgbot.Client.registerDocumentHandler(handler)
+}
+
+func (tgbot *TgBot[BOTDATA, USERDATA]) RegisterVoiceHandler(handler func(*Session[BOTDATA, USERDATA], *Voice, *Message)) {
+	tgbot.Client.registerVoiceHandler(handler)
+}
+
+func (tgbot *TgBot[BOTDATA, USERDATA]) RegisterVideoHandler(handler func(*Session[BOTDATA, USERDATA], *Video, *Message)) {
+	tgbot.Client.registerVideoHandler(handler)
+}
+
+func (tgbot *TgBot[BOTDATA, USERDATA]) RegisterAudioHandler(handler func(*Session[BOTDATA, USERDATA], *Audio, *Message)) {
+	tgbot.Client.registerAudioHandler(handler)
+}
+
+func (tgbot *TgBot[BOTDATA, USERDATA]) RegisterStickerHandler(handler func(*Session[BOTDATA, USERDATA], *Sticker, *Message)) {
+	tgbot.Client.registerStickerHandler(handler)
+}
+
+func (tgbot *TgBot[BOTDATA, USERDATA]) RegisterContactHandler(handler func(*Session[BOTDATA, USERDATA], *Contact, *Message)) {
+	tgbot.Client.registerContactHandler(handler)
+}
+
+func (tgbot *TgBot[BOTDATA, USERDATA]) RegisterLocationHandler(handler func(*Session[BOTDATA, USERDATA], *Location, *Message)) {
+	tgbot.Client.registerLocationHandler(handler)
+}
+
+func (tgbot *TgBot[BOTDATA, USERDATA]) RegisterVideoNoteHandler(handler func(*Session[BOTDATA, USERDATA], *VideoNote, *Message)) {
+	tgbot.Client.registerVideoNoteHandler(handler)
+}
+
+func (tgbot *TgBot[BOTDATA, USERDATA]) RegisterPollHandler(handler func(*Session[BOTDATA, USERDATA], *Poll, *Message)) {
+	tgbot.Client.registerPollHandler(handler)
+}
+
+func (tgbot *TgBot[BOTDATA, USERDATA]) RegisterDiceHandler(handler func(*Session[BOTDATA, USERDATA], *Dice, *Message)) {
+	tgbot.Client.registerDiceHandler(handler)
+}
+
+func (tgbot *TgBot[BOTDATA, USERDATA]) RegisterEditedMessageHandler(handler func(*Session[BOTDATA, USERDATA], *Message)) {
+	tgbot.Client.registerEditedMessageHandler(handler)
+}
+
+// RegisterChannelPostHandler routes channel posts to handler. Without it,
+// channel posts are processed like regular messages.
+func (tgbot *TgBot[BOTDATA, USERDATA]) RegisterChannelPostHandler(handler func(*Session[BOTDATA, USERDATA], *Message)) {
+	tgbot.Client.registerChannelPostHandler(handler)
+}
+
+func (tgbot *TgBot[BOTDATA, USERDATA]) RegisterEditedChannelPostHandler(handler func(*Session[BOTDATA, USERDATA], *Message)) {
+	tgbot.Client.registerEditedChannelPostHandler(handler)
+}
+
+// RegisterMyChatMemberHandler is called when the bot's own membership
+// changes, e.g. when it is added to, removed from or promoted in a chat.
+func (tgbot *TgBot[BOTDATA, USERDATA]) RegisterMyChatMemberHandler(handler func(*Session[BOTDATA, USERDATA], *ChatMemberUpdated)) {
+	tgbot.Client.registerMyChatMemberHandler(handler)
+}
+
+// RegisterChatMemberHandler is called when another member's status changes
+// in a chat where the bot is an administrator.
+func (tgbot *TgBot[BOTDATA, USERDATA]) RegisterChatMemberHandler(handler func(*Session[BOTDATA, USERDATA], *ChatMemberUpdated)) {
+	tgbot.Client.registerChatMemberHandler(handler)
+}
+
+func (tgbot *TgBot[BOTDATA, USERDATA]) RegisterChatJoinRequestHandler(handler func(*Session[BOTDATA, USERDATA], *ChatJoinRequest)) {
+	tgbot.Client.registerChatJoinRequestHandler(handler)
+}
+
+func (tgbot *TgBot[BOTDATA, USERDATA]) RegisterPollAnswerHandler(handler func(*Session[BOTDATA, USERDATA], *PollAnswer)) {
+	tgbot.Client.registerPollAnswerHandler(handler)
+}
+
+func (tgbot *TgBot[BOTDATA, USERDATA]) RegisterMessageReactionHandler(handler func(*Session[BOTDATA, USERDATA], *MessageReaction)) {
+	tgbot.Client.registerMessageReactionHandler(handler)
+}
+
+// RegisterInlineQueryHandler answers inline queries with the results returned
+// by handler. Returning nil leaves the query unanswered. Queries from people
+// who never started the bot come with a session that isn't stored.
+func (tgbot *TgBot[BOTDATA, USERDATA]) RegisterInlineQueryHandler(handler func(*Session[BOTDATA, USERDATA], *InlineQuery) *InlineQueryAnswer) {
+	tgbot.Client.registerInlineQueryHandler(handler)
+}
+
+// RegisterChosenInlineResultHandler requires inline feedback to be enabled
+// for the bot via @BotFather.
+func (tgbot *TgBot[BOTDATA, USERDATA]) RegisterChosenInlineResultHandler(handler func(*Session[BOTDATA, USERDATA], *ChosenInlineResult)) {
+	tgbot.Client.registerChosenInlineResultHandler(handler)
+}
+
+// RegisterQueryHandler binds a name to a query answer handler for use with
+// Session.SendNamedQuery.
+func (tgbot *TgBot[BOTDATA, USERDATA]) RegisterQueryHandler(name string, handler func(*Session[BOTDATA, USERDATA], string) *CallbackAnswer) {
+	tgbot.Client.registerQueryHandler(name, handler)
+}
+
+// RegisterCallbackHandler routes buttons created with EncodeCallbackData to
+// handler when their route starts with prefix; the longest matching prefix
+// wins. The "q" and "m" routes are used internally by queries and menus.
+func (tgbot *TgBot[BOTDATA, USERDATA]) RegisterCallbackHandler(prefix string, handler func(*Session[BOTDATA, USERDATA], CallbackData, *CallbackQuery) *CallbackAnswer) {
+	tgbot.Client.registerCallbackHandler(prefix, handler)
+}
+
+// EncodeCallbackData builds signed callback data for a stateless button
+// that remains valid for as long as the callback secret is unchanged.
+func (tgbot *TgBot[BOTDATA, USERDATA]) EncodeCallbackData(route string, args ...string) (string, error) {
+	return tgbot.Client.EncodeCallbackData(route, args...)
+}
+
+// RegisterMenu makes menu and its submenus known to the client so that
+// buttons on previously sent menus keep working after a restart.
+func (tgbot *TgBot[BOTDATA, USERDATA]) RegisterMenu(menu *Menu[BOTDATA, USERDATA]) {
+	tgbot.Client.registerMenu(menu)
+}
+
+// Me returns the identity of the bot as reported by getMe. It is nil until
+// Start has completed.
+func (tgbot *TgBot[BOTDATA, USERDATA]) Me() *BotIdentity {
+	return tgbot.Client.Me()
+}
+
+// Broadcast sends content in the background to every user that hasn't
+// blocked the bot. Jobs are persisted and resumed by Start.
+func (tgbot *TgBot[BOTDATA, USERDATA]) Broadcast(content BroadcastContent, config BroadcastConfig) (*BroadcastJob[BOTDATA, USERDATA], error) {
+	return tgbot.Client.Broadcast(content, config)
+}
+
+// RegisterBroadcastSegment names a filter for BroadcastConfig.Segment.
+// Segments must be registered before Start for their jobs to resume.
+func (tgbot *TgBot[BOTDATA, USERDATA]) RegisterBroadcastSegment(name string, filter func(*Session[BOTDATA, USERDATA]) bool) {
+	tgbot.Client.registerBroadcastSegment(name, filter)
+}
+
 func (tgbot *TgBot[BOTDATA, USERDATA]) Start() error {
 	return tgbot.Client.start()
 }
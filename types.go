@@ -3,18 +3,151 @@ package tgbot
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"strings"
+	"time"
 )
 
 var (
 	ErrForbidden    = errors.New("forbidden")
 	ErrChatNotFound = errors.New("chat not found or bot is not a member")
+
+	ErrMessageNotModified = errors.New("message is not modified")
+	ErrMessageTooLong     = errors.New("message is too long")
+
+	// ErrBadRequest, ErrUnauthorized, ErrServerError and ErrNetwork wrap
+	// the underlying error, which keeps Telegram's description.
+	ErrTooManyRequests = errors.New("too many requests")
+	ErrBadRequest      = errors.New("bad request")
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrServerError     = errors.New("telegram server error")
+	ErrNetwork         = errors.New("network error")
+
+	ErrUnknownQueryHandler = errors.New("unknown query handler")
+	ErrInvalidButton       = errors.New("inline keyboard button must have exactly one action")
 )
 
+// ChatMigratedError is returned when a group has been upgraded to a
+// supergroup, which has to be sent to by its new ID. It matches
+// ErrBadRequest with errors.Is.
+type ChatMigratedError struct {
+	NewChatID   int64
+	Description string
+}
+
+func (e *ChatMigratedError) Error() string {
+	return fmt.Sprintf("%s: migrated to chat %d", e.Description, e.NewChatID)
+}
+
+func (e *ChatMigratedError) Is(target error) bool {
+	return target == ErrBadRequest
+}
+
 type Update struct {
-	Message       *Message
-	CallbackQuery *CallbackQuery
+	Message            *Message
+	EditedMessage      *Message
+	ChannelPost        *Message
+	EditedChannelPost  *Message
+	CallbackQuery      *CallbackQuery
+	MyChatMember       *ChatMemberUpdated
+	ChatMember         *ChatMemberUpdated
+	ChatJoinRequest    *ChatJoinRequest
+	PollAnswer         *PollAnswer
+	MessageReaction    *MessageReaction
+	InlineQuery        *InlineQuery
+	ChosenInlineResult *ChosenInlineResult
+}
+
+type ChatMemberStatus string
+
+const (
+	ChatMemberStatusCreator       ChatMemberStatus = "creator"
+	ChatMemberStatusAdministrator ChatMemberStatus = "administrator"
+	ChatMemberStatusMember        ChatMemberStatus = "member"
+	ChatMemberStatusRestricted    ChatMemberStatus = "restricted"
+	ChatMemberStatusLeft          ChatMemberStatus = "left"
+	ChatMemberStatusKicked        ChatMemberStatus = "kicked"
+)
+
+type ChatMember struct {
+	UserID    int64
+	Status    ChatMemberStatus
+	IsMember  bool
+	UntilDate int
+}
+
+// IsPresent reports whether the member is currently part of the chat.
+// Restricted members are present only while IsMember is set.
+func (m ChatMember) IsPresent() bool {
+	switch m.Status {
+	case ChatMemberStatusCreator, ChatMemberStatusAdministrator, ChatMemberStatusMember:
+		return true
+	case ChatMemberStatusRestricted:
+		return m.IsMember
+	default:
+		return false
+	}
+}
+
+func (m ChatMember) IsAdmin() bool {
+	return m.Status == ChatMemberStatusCreator || m.Status == ChatMemberStatusAdministrator
+}
+
+type ChatMemberUpdated struct {
+	Chat           Chat
+	From           *MessageSender
+	Date           int
+	OldChatMember  ChatMember
+	NewChatMember  ChatMember
+	ViaJoinRequest bool
+}
+
+func (u *ChatMemberUpdated) Joined() bool {
+	return !u.OldChatMember.IsPresent() && u.NewChatMember.IsPresent()
+}
+
+func (u *ChatMemberUpdated) Left() bool {
+	return u.OldChatMember.IsPresent() && !u.NewChatMember.IsPresent()
+}
+
+func (u *ChatMemberUpdated) Promoted() bool {
+	return !u.OldChatMember.IsAdmin() && u.NewChatMember.IsAdmin()
+}
+
+func (u *ChatMemberUpdated) Demoted() bool {
+	return u.OldChatMember.IsAdmin() && !u.NewChatMember.IsAdmin()
+}
+
+type ChatJoinRequest struct {
+	Chat       Chat
+	From       *MessageSender
+	UserChatID int64
+	Date       int
+	Bio        string
+}
+
+type PollAnswer struct {
+	PollID    string
+	User      *MessageSender
+	VoterChat *Chat
+	OptionIDs []int
+}
+
+type Reaction struct {
+	Type          string
+	Emoji         string
+	CustomEmojiID string
+}
+
+type MessageReaction struct {
+	Chat        Chat
+	MessageID   int
+	User        *MessageSender
+	ActorChat   *Chat
+	Date        int
+	OldReaction []Reaction
+	NewReaction []Reaction
 }
 
 type ParseMode int
@@ -22,27 +155,169 @@ type ParseMode int
 const (
 	ParseModePlain ParseMode = iota
 	ParseModeHTML
+	// ParseModeMarkdown is sent as Telegram's MarkdownV2.
 	ParseModeMarkdown
+	// ParseModeMarkdownLegacy is Telegram's original Markdown, for texts
+	// written before MarkdownV2.
+	ParseModeMarkdownLegacy
 )
 
+// ParseModeMarkdownV2 is another name for ParseModeMarkdown.
+const ParseModeMarkdownV2 = ParseModeMarkdown
+
 type ReplyMarkup interface{}
 
 type InlineKeyboardMarkup struct {
 	InlineKeyboard [][]InlineKeyboardButton `json:"inline_keyboard"`
 }
 
+// InlineKeyboardButton must have exactly one action set besides Text.
+// CallbackGame buttons must be the first button of the first row and Pay
+// buttons are only valid on invoices. The switch queries may point to an
+// empty string, which opens inline mode without a query.
 type InlineKeyboardButton struct {
-	Text         string `json:"text"`
-	URL          string `json:"url,omitempty"`
-	CallbackData string `json:"callback_data,omitempty"`
+	Text                         string                       `json:"text"`
+	URL                          string                       `json:"url,omitempty"`
+	CallbackData                 string                       `json:"callback_data,omitempty"`
+	WebApp                       *WebAppInfo                  `json:"web_app,omitempty"`
+	LoginURL                     *LoginURL                    `json:"login_url,omitempty"`
+	SwitchInlineQuery            *string                      `json:"switch_inline_query,omitempty"`
+	SwitchInlineQueryCurrentChat *string                      `json:"switch_inline_query_current_chat,omitempty"`
+	SwitchInlineQueryChosenChat  *SwitchInlineQueryChosenChat `json:"switch_inline_query_chosen_chat,omitempty"`
+	CopyText                     string                       `json:"copy_text,omitempty"`
+	CallbackGame                 bool                         `json:"callback_game,omitempty"`
+	Pay                          bool                         `json:"pay,omitempty"`
+}
+
+type WebAppInfo struct {
+	URL string `json:"url"`
+}
+
+type LoginURL struct {
+	URL                string `json:"url"`
+	ForwardText        string `json:"forward_text,omitempty"`
+	BotUsername        string `json:"bot_username,omitempty"`
+	RequestWriteAccess bool   `json:"request_write_access,omitempty"`
+}
+
+type SwitchInlineQueryChosenChat struct {
+	Query             string `json:"query,omitempty"`
+	AllowUserChats    bool   `json:"allow_user_chats,omitempty"`
+	AllowBotChats     bool   `json:"allow_bot_chats,omitempty"`
+	AllowGroupChats   bool   `json:"allow_group_chats,omitempty"`
+	AllowChannelChats bool   `json:"allow_channel_chats,omitempty"`
+}
+
+func (b InlineKeyboardButton) actionCount() int {
+	count := 0
+	for _, set := range []bool{
+		b.URL != "",
+		b.CallbackData != "",
+		b.WebApp != nil,
+		b.LoginURL != nil,
+		b.SwitchInlineQuery != nil,
+		b.SwitchInlineQueryCurrentChat != nil,
+		b.SwitchInlineQueryChosenChat != nil,
+		b.CopyText != "",
+		b.CallbackGame,
+		b.Pay,
+	} {
+		if set {
+			count++
+		}
+	}
+	return count
+}
+
+func (b InlineKeyboardButton) Validate() error {
+	if n := b.actionCount(); n != 1 {
+		return fmt.Errorf("%w: %q has %d actions", ErrInvalidButton, b.Text, n)
+	}
+	if len(b.CallbackData) > maxCallbackDataLength {
+		return fmt.Errorf("%w: %q", ErrCallbackDataTooLong, b.Text)
+	}
+	return nil
+}
+
+func (m *InlineKeyboardMarkup) Validate() error {
+	for _, row := range m.InlineKeyboard {
+		for _, btn := range row {
+			if err := btn.Validate(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateReplyMarkup(markup ReplyMarkup) error {
+	switch m := markup.(type) {
+	case *InlineKeyboardMarkup:
+		if m != nil {
+			return m.Validate()
+		}
+	case InlineKeyboardMarkup:
+		return m.Validate()
+	}
+	return nil
+}
+
+type ReplyKeyboardMarkup struct {
+	Keyboard              [][]KeyboardButton `json:"keyboard"`
+	IsPersistent          bool               `json:"is_persistent,omitempty"`
+	ResizeKeyboard        bool               `json:"resize_keyboard,omitempty"`
+	OneTimeKeyboard       bool               `json:"one_time_keyboard,omitempty"`
+	InputFieldPlaceholder string             `json:"input_field_placeholder,omitempty"`
+	Selective             bool               `json:"selective,omitempty"`
+}
+
+type KeyboardButton struct {
+	Text            string `json:"text"`
+	RequestContact  bool   `json:"request_contact,omitempty"`
+	RequestLocation bool   `json:"request_location,omitempty"`
+}
+
+type ReplyKeyboardRemove struct {
+	Selective bool `json:"selective,omitempty"`
+}
+
+type ForceReply struct {
+	InputFieldPlaceholder string `json:"input_field_placeholder,omitempty"`
+	Selective             bool   `json:"selective,omitempty"`
 }
 
+// SendMessageOpts formats the text either with ParseMode or with Entities.
 type SendMessageOpts struct {
 	ReplyToMessageID int
 	ParseMode        ParseMode
+	Entities         []MessageEntity
 	ReplyMarkup      ReplyMarkup
 }
 
+// SendMediaOpts applies to media sends; ParseMode formats the caption. Video
+// notes and stickers carry no caption.
+type SendMediaOpts struct {
+	Caption          string
+	ReplyToMessageID int
+	ParseMode        ParseMode
+	ReplyMarkup      ReplyMarkup
+}
+
+// CallbackAnswer is shown to the user after tapping an inline button: Text
+// as a toast, or as a modal alert with ShowAlert. URL opens a game or a
+// t.me/<bot>?start= link, and CacheTime lets clients cache the answer.
+type CallbackAnswer struct {
+	Text      string
+	ShowAlert bool
+	URL       string
+	CacheTime int
+}
+
+type EditMessageOpts struct {
+	ParseMode   ParseMode
+	ReplyMarkup ReplyMarkup
+}
+
 type VideoMeta struct {
 	Duration int
 	Width    int
@@ -50,12 +325,43 @@ type VideoMeta struct {
 }
 
 type BotAPI interface {
-	SendMessage(ctx context.Context, chatID int64, text string, opts *SendMessageOpts) error
-	SendPhoto(ctx context.Context, chatID int64, photo io.Reader, filename string) error
-	SendVideo(ctx context.Context, chatID int64, video io.Reader, filename string, meta *VideoMeta) error
-	SendAudio(ctx context.Context, chatID int64, audio io.Reader, filename string) error
-	SendDocument(ctx context.Context, chatID int64, doc io.Reader, filename string) error
-	answerCallbackQuery(ctx context.Context, callbackQueryID string) error
+	GetMe(ctx context.Context) (*BotIdentity, error)
+	SendMessage(ctx context.Context, chatID int64, text string, opts *SendMessageOpts) (*Message, error)
+	SendPhoto(ctx context.Context, chatID int64, photo InputFile, opts *SendMediaOpts) (*Message, error)
+	SendVideo(ctx context.Context, chatID int64, video InputFile, meta *VideoMeta, opts *SendMediaOpts) (*Message, error)
+	SendAudio(ctx context.Context, chatID int64, audio InputFile, opts *SendMediaOpts) (*Message, error)
+	SendDocument(ctx context.Context, chatID int64, doc InputFile, opts *SendMediaOpts) (*Message, error)
+	SendMediaGroup(ctx context.Context, chatID int64, media []InputMedia, replyToMessageID int) ([]*Message, error)
+	SendVoice(ctx context.Context, chatID int64, voice InputFile, opts *SendMediaOpts) (*Message, error)
+	SendVideoNote(ctx context.Context, chatID int64, note InputFile, opts *SendMediaOpts) (*Message, error)
+	SendAnimation(ctx context.Context, chatID int64, animation InputFile, opts *SendMediaOpts) (*Message, error)
+	SendSticker(ctx context.Context, chatID int64, sticker InputFile, opts *SendMediaOpts) (*Message, error)
+	SendLocation(ctx context.Context, chatID int64, location Location, opts *SendMessageOpts) (*Message, error)
+	SendVenue(ctx context.Context, chatID int64, venue Venue, opts *SendMessageOpts) (*Message, error)
+	SendContact(ctx context.Context, chatID int64, contact Contact, opts *SendMessageOpts) (*Message, error)
+	SendPoll(ctx context.Context, chatID int64, poll PollConfig, opts *SendMessageOpts) (*Message, error)
+	SendDice(ctx context.Context, chatID int64, emoji string, opts *SendMessageOpts) (*Message, error)
+	EditMessageText(ctx context.Context, chatID int64, messageID int, text string, opts *EditMessageOpts) (*Message, error)
+	EditMessageReplyMarkup(ctx context.Context, chatID int64, messageID int, markup ReplyMarkup) (*Message, error)
+	EditInlineMessageText(ctx context.Context, inlineMessageID string, text string, opts *EditMessageOpts) error
+	EditInlineMessageReplyMarkup(ctx context.Context, inlineMessageID string, markup ReplyMarkup) error
+	DeleteMessage(ctx context.Context, chatID int64, messageID int) error
+	SendChatAction(ctx context.Context, chatID int64, action ChatAction) error
+	GetFile(ctx context.Context, fileID string) (*File, error)
+	DownloadFile(ctx context.Context, fileID string, w io.Writer) error
+	ApproveChatJoinRequest(ctx context.Context, chatID int64, userID int64) error
+	DeclineChatJoinRequest(ctx context.Context, chatID int64, userID int64) error
+	answerCallbackQuery(ctx context.Context, callbackQueryID string, answer *CallbackAnswer) error
+	answerInlineQuery(ctx context.Context, inlineQueryID string, answer *InlineQueryAnswer) error
+}
+
+type BotIdentity struct {
+	ID                      int64
+	Username                string
+	FirstName               string
+	CanJoinGroups           bool
+	CanReadAllGroupMessages bool
+	SupportsInlineQueries   bool
 }
 
 type MessageSender struct {
@@ -69,12 +375,219 @@ type Chat struct {
 
 func (c Chat) IsGroup() bool      { return c.Type == "group" }
 func (c Chat) IsSuperGroup() bool { return c.Type == "supergroup" }
+func (c Chat) IsPrivate() bool    { return c.Type == "private" }
+func (c Chat) IsChannel() bool    { return c.Type == "channel" }
 
 type Message struct {
-	MessageID int
-	Chat      Chat
-	From      *MessageSender
-	Text      string
+	MessageID       int
+	Chat            Chat
+	From            *MessageSender
+	Text            string
+	Entities        []MessageEntity
+	Caption         string
+	CaptionEntities []MessageEntity
+	Photo           []PhotoSize
+	Document        *Document
+	Voice           *Voice
+	Video           *Video
+	Audio           *Audio
+	Sticker         *Sticker
+	Contact         *Contact
+	Location        *Location
+	Animation       *Animation
+	VideoNote       *VideoNote
+	Poll            *Poll
+	Dice            *Dice
+
+	ReplyToMessageID int
+	ReplyToMessage   *Message
+}
+
+type Contact struct {
+	PhoneNumber string
+	FirstName   string
+	LastName    string
+	UserID      int64
+}
+
+type Location struct {
+	Latitude  float64
+	Longitude float64
+}
+
+type Venue struct {
+	Location Location
+	Title    string
+	Address  string
+}
+
+type PollType string
+
+const (
+	PollRegular PollType = "regular"
+	PollQuiz    PollType = "quiz"
+)
+
+type PollOption struct {
+	Text       string
+	VoterCount int
+}
+
+type Poll struct {
+	ID                    string
+	Question              string
+	Options               []PollOption
+	TotalVoterCount       int
+	IsClosed              bool
+	IsAnonymous           bool
+	Type                  PollType
+	AllowsMultipleAnswers bool
+	CorrectOptionID       int
+}
+
+// PollConfig describes a poll to send. Like Telegram's default, polls are
+// anonymous unless IsPublic is set; channels only allow anonymous polls.
+// CorrectOptionID and Explanation only apply to quizzes.
+type PollConfig struct {
+	Question              string
+	Options               []string
+	Type                  PollType
+	IsPublic              bool
+	AllowsMultipleAnswers bool
+	CorrectOptionID       int
+	Explanation           string
+	ExplanationParseMode  ParseMode
+	OpenPeriod            int
+}
+
+const (
+	DiceDie         = "🎲"
+	DiceDarts       = "🎯"
+	DiceBasketball  = "🏀"
+	DiceFootball    = "⚽"
+	DiceBowling     = "🎳"
+	DiceSlotMachine = "🎰"
+)
+
+type Dice struct {
+	Emoji string
+	Value int
+}
+
+type MessageEntityType string
+
+const (
+	EntityMention       MessageEntityType = "mention"
+	EntityHashtag       MessageEntityType = "hashtag"
+	EntityCashtag       MessageEntityType = "cashtag"
+	EntityBotCommand    MessageEntityType = "bot_command"
+	EntityURL           MessageEntityType = "url"
+	EntityEmail         MessageEntityType = "email"
+	EntityPhoneNumber   MessageEntityType = "phone_number"
+	EntityBold          MessageEntityType = "bold"
+	EntityItalic        MessageEntityType = "italic"
+	EntityUnderline     MessageEntityType = "underline"
+	EntityStrikethrough MessageEntityType = "strikethrough"
+	EntitySpoiler       MessageEntityType = "spoiler"
+	EntityBlockquote    MessageEntityType = "blockquote"
+	EntityCode          MessageEntityType = "code"
+	EntityPre           MessageEntityType = "pre"
+	EntityTextLink      MessageEntityType = "text_link"
+	EntityTextMention   MessageEntityType = "text_mention"
+	EntityCustomEmoji   MessageEntityType = "custom_emoji"
+)
+
+// MessageEntity describes a span of Text or Caption. Offset and Length are
+// measured in UTF-16 code units, as reported by Telegram.
+type MessageEntity struct {
+	Type     MessageEntityType
+	Offset   int
+	Length   int
+	URL      string
+	User     *MessageSender
+	Language string
+}
+
+type PhotoSize struct {
+	FileID       string
+	FileUniqueID string
+	Width        int
+	Height       int
+	FileSize     int
+}
+
+type Document struct {
+	FileID       string
+	FileUniqueID string
+	FileName     string
+	MimeType     string
+	FileSize     int64
+}
+
+type Voice struct {
+	FileID       string
+	FileUniqueID string
+	Duration     int
+	MimeType     string
+	FileSize     int64
+}
+
+type Video struct {
+	FileID       string
+	FileUniqueID string
+	Width        int
+	Height       int
+	Duration     int
+	FileName     string
+	MimeType     string
+	FileSize     int64
+}
+
+type Audio struct {
+	FileID       string
+	FileUniqueID string
+	Duration     int
+	Performer    string
+	Title        string
+	FileName     string
+	MimeType     string
+	FileSize     int64
+}
+
+type Sticker struct {
+	FileID       string
+	FileUniqueID string
+	Emoji        string
+	SetName      string
+	IsAnimated   bool
+	IsVideo      bool
+	FileSize     int
+}
+
+type Animation struct {
+	FileID       string
+	FileUniqueID string
+	Width        int
+	Height       int
+	Duration     int
+	FileName     string
+	MimeType     string
+	FileSize     int64
+}
+
+type VideoNote struct {
+	FileID       string
+	FileUniqueID string
+	Length       int
+	Duration     int
+	FileSize     int
+}
+
+type File struct {
+	FileID       string
+	FileUniqueID string
+	FileSize     int64
+	FilePath     string
 }
 
 type CallbackQuery struct {
@@ -85,38 +598,76 @@ type CallbackQuery struct {
 	InlineMessageID string
 }
 
+// HasMedia reports whether the message carries a non-text payload, including
+// shared contacts, locations, polls and dice.
+func (m *Message) HasMedia() bool {
+	return len(m.Photo) > 0 || m.Document != nil || m.Voice != nil || m.Video != nil || m.Audio != nil || m.Sticker != nil ||
+		m.Contact != nil || m.Location != nil || m.VideoNote != nil || m.Poll != nil || m.Dice != nil
+}
+
+// LargestPhoto returns the highest resolution variant of an incoming photo.
+func (m *Message) LargestPhoto() *PhotoSize {
+	if len(m.Photo) == 0 {
+		return nil
+	}
+	return &m.Photo[len(m.Photo)-1]
+}
+
 func (m *Message) IsCommand() bool {
-	return strings.HasPrefix(m.Text, "/")
+	return m.commandEntity() != nil
+}
+
+func (m *Message) commandEntity() *MessageEntity {
+	if len(m.Entities) == 0 {
+		return nil
+	}
+	e := &m.Entities[0]
+	if e.Type != EntityBotCommand || e.Offset != 0 {
+		return nil
+	}
+	return e
 }
 
 func (m *Message) Command() string {
-	if !m.IsCommand() {
+	e := m.commandEntity()
+	if e == nil {
 		return ""
 	}
-	s := strings.TrimPrefix(m.Text, "/")
-	if i := strings.IndexAny(s, " @"); i > 0 {
-		s = s[:i]
-	} else if i := strings.Index(s, " "); i > 0 {
+	s := strings.TrimPrefix(entityText(m.Text, *e), "/")
+	if i := strings.Index(s, "@"); i >= 0 {
 		s = s[:i]
 	}
 	return s
 }
 
-func (m *Message) CommandArguments() string {
-	if !m.IsCommand() {
+// CommandTarget returns the bot username a command is addressed to, as in
+// "/start@SomeBot", or an empty string for unaddressed commands.
+func (m *Message) CommandTarget() string {
+	e := m.commandEntity()
+	if e == nil {
 		return ""
 	}
-	s := strings.TrimSpace(strings.TrimPrefix(m.Text, "/"))
-	if i := strings.Index(s, " "); i >= 0 {
-		return strings.TrimSpace(s[i+1:])
+	s := entityText(m.Text, *e)
+	if i := strings.Index(s, "@"); i >= 0 {
+		return s[i+1:]
 	}
 	return ""
 }
 
+func (m *Message) CommandArguments() string {
+	e := m.commandEntity()
+	if e == nil {
+		return ""
+	}
+	return strings.TrimSpace(textAfterEntity(m.Text, *e))
+}
+
 type User[USERDATA any] struct {
-	ID       int64    `firestore:"id"`
-	Blocked  bool     `firestore:"blocked"`
-	UserData USERDATA `firestore:"userdata"`
+	ID          int64     `firestore:"id"`
+	Blocked     bool      `firestore:"blocked"`
+	BlockedAt   time.Time `firestore:"blockedAt,omitempty"`
+	UnblockedAt time.Time `firestore:"unblockedAt,omitempty"`
+	UserData    USERDATA  `firestore:"userdata"`
 }
 
 type Preference[BOTDATA any] struct {
@@ -140,12 +691,21 @@ type CommandSession struct {
 const (
 	errChatNotFound = "Bad Request: chat not found"
 	errNotMember    = "Forbidden: bot is not a member of the channel chat"
+
+	errMessageNotModified = "message is not modified"
+	errMessageTooLong     = "message is too long"
+	errCaptionTooLong     = "caption is too long"
+	errTelegramResponse   = "error response from telegram"
+	errDoRequest          = "error do request"
+	errDecodeBody         = "error decode response body"
+	errDecodeResult       = "error decode response result"
 )
 
 const (
 	CmdStart     = "start"
 	CmdBotReload = "botreload"
 	CmdBotStat   = "botstat"
+	CmdBroadcast = "broadcast"
 )
 
 type CmdResult int